@@ -1,20 +1,31 @@
 package http
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"go-source/bootstrap"
 	"go-source/config"
 	logger "go-source/pkg/log"
+	"go-source/pkg/utils"
 	"net/http"
 	"sync"
 
 	"github.com/labstack/echo/v4"
 )
 
+const (
+	healthStatusUp   = "UP"
+	healthStatusDown = "DOWN"
+)
+
+// HealthProbe checks one dependency for the readiness check, a nil error means healthy.
+type HealthProbe func(ctx context.Context) error
+
 var (
-	healthCheck bool
-	mu          sync.RWMutex
+	healthCheck  bool
+	healthProbes = make(map[string]HealthProbe)
+	mu           sync.RWMutex
 )
 
 func SetHealthCheck(status bool) {
@@ -23,6 +34,57 @@ func SetHealthCheck(status bool) {
 	healthCheck = status
 }
 
+func RegisterHealthProbe(name string, probe HealthProbe) {
+	mu.Lock()
+	defer mu.Unlock()
+	healthProbes[name] = probe
+}
+
+// health is the liveness check, it doesn't depend on the probes so a dependency
+// down doesn't restart the service.
+func health(c echo.Context) error {
+	mu.RLock()
+	status := healthCheck
+	mu.RUnlock()
+
+	if !status {
+		return c.JSON(http.StatusServiceUnavailable, map[string]string{"status": healthStatusDown})
+	}
+	return c.JSON(http.StatusOK, map[string]string{"status": healthStatusUp})
+}
+
+// ready is the readiness check, it runs the probes and answers only UP or DOWN for
+// each dependency, the errors are logged.
+func ready(c echo.Context) error {
+	mu.RLock()
+	status := healthCheck
+	probes := make(map[string]HealthProbe, len(healthProbes))
+	for name, probe := range healthProbes {
+		probes[name] = probe
+	}
+	mu.RUnlock()
+
+	if !status {
+		return c.JSON(http.StatusServiceUnavailable, map[string]string{"status": healthStatusDown})
+	}
+
+	ctx := c.Request().Context()
+	code := http.StatusOK
+	resp := map[string]string{"status": healthStatusUp}
+	for name, probe := range probes {
+		if err := probe(ctx); err != nil {
+			logger.GetLogger().AddTraceInfoContextRequest(ctx).Warn().Err(err).Msgf("health probe %s failed", name)
+			code = http.StatusServiceUnavailable
+			resp["status"] = healthStatusDown
+			resp[name] = healthStatusDown
+			continue
+		}
+		resp[name] = healthStatusUp
+	}
+
+	return c.JSON(code, resp)
+}
+
 type ServInterface interface {
 	Start(e *echo.Echo)
 }
@@ -37,6 +99,8 @@ func NewHttpServe(handlers *bootstrap.Handlers) *Server {
 func (app *Server) Start(e *echo.Echo) {
 	log := logger.GetLogger()
 	httpPort := config.GetInstance().HttpPort
+	e.GET(utils.PathHealth, health)
+	e.GET(utils.PathReady, ready)
 	go func() {
 		err := e.Start(fmt.Sprintf(":%d", httpPort))
 		if err != nil && !errors.Is(err, http.ErrServerClosed) {
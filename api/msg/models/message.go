@@ -1,5 +1,7 @@
 package models
 
+// ConsumerMessage is the untyped message of the existing topics, new topics publish
+// an event.Envelope and are consumed with an event.Router.
 type ConsumerMessage struct {
 	EventType string      `json:"eventType"`
 	Data      interface{} `json:"data"`
@@ -43,6 +43,7 @@ func main() {
 	if err != nil {
 		log.Fatal().Msgf("Connect redis failed: %s", err)
 	}
+	http.RegisterHealthProbe("redis", redisClient.HealthCheck)
 
 	// Initialize application dependencies following clean architecture pattern
 	storage := bootstrap.NewDatabaseConnection(ctx)
@@ -0,0 +1,78 @@
+package authz
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	decisionKeyPrefix = "authz:decision:"
+
+	DefaultDecisionTTL = 30 * time.Second
+)
+
+var (
+	ErrNilRedisClient   = errors.New("authz: redis client is nil")
+	ErrDecisionNotFound = errors.New("authz: decision not found")
+)
+
+// DecisionCache keeps the decisions of the remote authorize service, the local
+// decisions are cheaper to evaluate than a cache round trip.
+type DecisionCache interface {
+	Get(ctx context.Context, key string) (*Decision, error)
+	Set(ctx context.Context, key string, decision *Decision) error
+}
+
+var _ DecisionCache = (*RedisDecisionCache)(nil)
+
+type RedisDecisionCache struct {
+	client redis.UniversalClient
+	ttl    time.Duration
+}
+
+// NewRedisDecisionCache keeps the decisions for ttl, short so permission changes
+// apply quickly.
+func NewRedisDecisionCache(client redis.UniversalClient, ttl time.Duration) *RedisDecisionCache {
+	if ttl <= 0 {
+		ttl = DefaultDecisionTTL
+	}
+	return &RedisDecisionCache{client: client, ttl: ttl}
+}
+
+func (c *RedisDecisionCache) Get(ctx context.Context, key string) (*Decision, error) {
+	if c.client == nil {
+		return nil, ErrNilRedisClient
+	}
+
+	data, err := c.client.Get(ctx, decisionKeyPrefix+key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrDecisionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var decision Decision
+	if err := json.Unmarshal(data, &decision); err != nil {
+		return nil, err
+	}
+
+	return &decision, nil
+}
+
+func (c *RedisDecisionCache) Set(ctx context.Context, key string, decision *Decision) error {
+	if c.client == nil {
+		return ErrNilRedisClient
+	}
+
+	data, err := json.Marshal(decision)
+	if err != nil {
+		return err
+	}
+
+	return c.client.Set(ctx, decisionKeyPrefix+key, data, c.ttl).Err()
+}
@@ -0,0 +1,43 @@
+package authz
+
+import (
+	"encoding/json"
+	"go-source/pkg/database/mongodb"
+	"time"
+)
+
+// AuthzConfig selects the policy source: the inline JSON Policy, else PolicyFile,
+// else the authz_roles collection.
+type AuthzConfig struct {
+	Policy         string        `env:"POLICY"`
+	PolicyFile     string        `env:"POLICY_FILE"`
+	ReloadInterval time.Duration `env:"RELOAD_INTERVAL" envDefault:"1m"`
+	RolesClaim     string        `env:"ROLES_CLAIM" envDefault:"roles"`
+	DecisionTTL    time.Duration `env:"DECISION_TTL" envDefault:"30s"`
+}
+
+// NewEngineFromConfig builds the engine of cfg, db is only used without Policy and PolicyFile.
+func NewEngineFromConfig(cfg AuthzConfig, db *mongodb.DatabaseStorage) (*Engine, error) {
+	var source PolicySource
+	switch {
+	case cfg.Policy != "":
+		var policy Policy
+		if err := json.Unmarshal([]byte(cfg.Policy), &policy); err != nil {
+			return nil, err
+		}
+		source = NewStaticSource(&policy)
+
+	case cfg.PolicyFile != "":
+		source = NewFileSource(cfg.PolicyFile)
+
+	default:
+		source = NewMongoSource(db)
+	}
+
+	opts := []EngineOption{WithReloadInterval(cfg.ReloadInterval)}
+	if cfg.RolesClaim != "" {
+		opts = append(opts, WithRolesClaim(cfg.RolesClaim))
+	}
+
+	return NewEngine(source, opts...), nil
+}
@@ -0,0 +1,275 @@
+package authz
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	logger "go-source/pkg/log"
+	"go-source/pkg/utils"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultReloadInterval = time.Minute
+	defaultRolesClaim     = "roles"
+	defaultProfileClaim   = "profile"
+)
+
+type Effect string
+
+const (
+	EffectAllow Effect = "ALLOW"
+	EffectDeny  Effect = "DENY"
+	// EffectNotApplicable is returned when no permission of the policy matches the
+	// route, so the decision can be left to the remote authorize service.
+	EffectNotApplicable Effect = "NOT_APPLICABLE"
+)
+
+type Decision struct {
+	Effect Effect `json:"effect"`
+	// Role granting the permission when allowed by the policy
+	Role string `json:"role,omitempty"`
+	// Profile is the X-Me-Profile header returned by the remote authorize service
+	Profile string `json:"profile,omitempty"`
+	// StatusCode and Body are the response of the remote authorize service when denied
+	StatusCode int    `json:"statusCode,omitempty"`
+	Body       []byte `json:"body,omitempty"`
+}
+
+func (d Decision) Allowed() bool {
+	return d.Effect == EffectAllow
+}
+
+type compiledPolicy struct {
+	// roles maps a role to its permissions, including the inherited ones
+	roles map[string][]Permission
+	all   []Permission
+}
+
+// Engine evaluates the policy locally. The policy is loaded from its source and
+// reloaded periodically, a failed reload keeps the previous policy.
+type Engine struct {
+	source         PolicySource
+	reloadInterval time.Duration
+	rolesClaim     string
+	profileClaim   string
+
+	policy atomic.Pointer[compiledPolicy]
+
+	started  atomic.Bool
+	stopOnce sync.Once
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+type EngineOption func(*Engine)
+
+// WithReloadInterval sets how often the policy is reloaded, zero disables it.
+func WithReloadInterval(interval time.Duration) EngineOption {
+	return func(e *Engine) {
+		e.reloadInterval = interval
+	}
+}
+
+// WithRolesClaim sets the JWT claim holding the roles, a string or a list of strings.
+func WithRolesClaim(claim string) EngineOption {
+	return func(e *Engine) {
+		e.rolesClaim = claim
+	}
+}
+
+// WithProfileClaim sets the JWT claim holding the caller profile, set as X-Me-Profile
+// when the engine allows a request.
+func WithProfileClaim(claim string) EngineOption {
+	return func(e *Engine) {
+		e.profileClaim = claim
+	}
+}
+
+func NewEngine(source PolicySource, opts ...EngineOption) *Engine {
+	e := &Engine{
+		source:         source,
+		reloadInterval: defaultReloadInterval,
+		rolesClaim:     defaultRolesClaim,
+		profileClaim:   defaultProfileClaim,
+		stop:           make(chan struct{}),
+		done:           make(chan struct{}),
+	}
+
+	for _, opt := range opts {
+		opt(e)
+	}
+
+	return e
+}
+
+// Load loads the policy from the source and replaces the current one.
+func (e *Engine) Load(ctx context.Context) error {
+	policy, err := e.source.Load(ctx)
+	if err != nil {
+		return err
+	}
+
+	compiled, err := compile(policy)
+	if err != nil {
+		return err
+	}
+
+	e.policy.Store(compiled)
+
+	return nil
+}
+
+// Start loads the policy then reloads it in background until ctx is done or Stop is called.
+func (e *Engine) Start(ctx context.Context) error {
+	if err := e.Load(ctx); err != nil {
+		return err
+	}
+
+	if e.reloadInterval <= 0 || !e.started.CompareAndSwap(false, true) {
+		return nil
+	}
+
+	go e.reloadLoop(ctx)
+
+	return nil
+}
+
+// Stop stops the reload and waits for it to return.
+func (e *Engine) Stop() {
+	e.stopOnce.Do(func() {
+		close(e.stop)
+	})
+
+	if e.started.Load() {
+		<-e.done
+	}
+}
+
+// Authorize decides whether one of roles can call method on path. The decision is
+// not applicable while no policy is loaded or when no permission matches the route.
+func (e *Engine) Authorize(roles []string, method, path string) Decision {
+	policy := e.policy.Load()
+	if policy == nil {
+		return Decision{Effect: EffectNotApplicable}
+	}
+
+	for _, role := range append([]string{RolePublic}, roles...) {
+		for _, perm := range policy.roles[role] {
+			if perm.matches(method, path) {
+				return Decision{Effect: EffectAllow, Role: role}
+			}
+		}
+	}
+
+	for _, perm := range policy.all {
+		if perm.matches(method, path) {
+			return Decision{Effect: EffectDeny}
+		}
+	}
+
+	return Decision{Effect: EffectNotApplicable}
+}
+
+// Roles returns the roles in the roles claim of verified claims.
+func (e *Engine) Roles(claims map[string]interface{}) []string {
+	switch value := claims[e.rolesClaim].(type) {
+	case string:
+		return strings.Fields(value)
+	case []string:
+		return value
+	case []interface{}:
+		roles := make([]string, 0, len(value))
+		for _, v := range value {
+			if role, ok := v.(string); ok {
+				roles = append(roles, role)
+			}
+		}
+		return roles
+	default:
+		return nil
+	}
+}
+
+// Profile returns the profile claim of verified claims, JSON encoded when it is not
+// a string, empty when missing.
+func (e *Engine) Profile(claims map[string]interface{}) string {
+	switch value := claims[e.profileClaim].(type) {
+	case nil:
+		return ""
+	case string:
+		return value
+	default:
+		data, err := json.Marshal(value)
+		if err != nil {
+			return ""
+		}
+		return string(data)
+	}
+}
+
+func (e *Engine) reloadLoop(ctx context.Context) {
+	defer close(e.done)
+
+	ticker := time.NewTicker(e.reloadInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-e.stop:
+			return
+		case <-ticker.C:
+			if err := e.Load(ctx); err != nil {
+				logger.GetLogger().Err(err).Msg("authz reload policy failed, previous policy kept")
+			}
+		}
+	}
+}
+
+func (p Permission) matches(method, path string) bool {
+	return (p.Method == MethodAny || strings.EqualFold(p.Method, method)) && utils.APIPathMatch(p.Path, path)
+}
+
+func compile(policy *Policy) (*compiledPolicy, error) {
+	byName := make(map[string]Role, len(policy.Roles))
+	for _, role := range policy.Roles {
+		if _, ok := byName[role.Name]; ok {
+			return nil, fmt.Errorf("authz: role %s defined twice", role.Name)
+		}
+		byName[role.Name] = role
+	}
+
+	compiled := &compiledPolicy{roles: make(map[string][]Permission, len(byName))}
+	for name, role := range byName {
+		compiled.all = append(compiled.all, role.Permissions...)
+
+		// expand inherited roles, a role already visited is skipped so cycles are harmless
+		visited := map[string]bool{name: true}
+		queue := append([]string(nil), role.Inherits...)
+		perms := append([]Permission(nil), role.Permissions...)
+		for len(queue) > 0 {
+			next := queue[0]
+			queue = queue[1:]
+			if visited[next] {
+				continue
+			}
+			visited[next] = true
+
+			inherited, ok := byName[next]
+			if !ok {
+				return nil, fmt.Errorf("authz: role %s inherits unknown role %s", name, next)
+			}
+			perms = append(perms, inherited.Permissions...)
+			queue = append(queue, inherited.Inherits...)
+		}
+
+		compiled.roles[name] = perms
+	}
+
+	return compiled, nil
+}
@@ -0,0 +1,78 @@
+package authz
+
+import (
+	"context"
+	"encoding/json"
+	logger "go-source/pkg/log"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writePolicy(t *testing.T, path string, policy Policy) {
+	t.Helper()
+
+	data, err := json.Marshal(policy)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path, data, 0o600))
+}
+
+func TestEngine_Authorize(t *testing.T) {
+	engine := NewEngine(NewStaticSource(&Policy{Roles: []Role{
+		{Name: RolePublic, Permissions: []Permission{{Method: "GET", Path: "/api/v1/health"}}},
+		{Name: "viewer", Permissions: []Permission{{Method: "GET", Path: "/api/v1/users/:id"}}},
+		{Name: "admin", Permissions: []Permission{{Method: MethodAny, Path: "/api/v1/users/:id"}}, Inherits: []string{"viewer"}},
+	}}), WithReloadInterval(0))
+
+	assert.Equal(t, EffectNotApplicable, engine.Authorize([]string{"admin"}, "GET", "/api/v1/users/1").Effect)
+
+	require.NoError(t, engine.Start(context.Background()))
+	defer engine.Stop()
+
+	tests := []struct {
+		roles  []string
+		method string
+		path   string
+		effect Effect
+	}{
+		{roles: nil, method: "GET", path: "/api/v1/health", effect: EffectAllow},
+		{roles: []string{"viewer"}, method: "GET", path: "/api/v1/users/1", effect: EffectAllow},
+		{roles: []string{"viewer"}, method: "DELETE", path: "/api/v1/users/1", effect: EffectDeny},
+		{roles: []string{"admin"}, method: "DELETE", path: "/api/v1/users/1", effect: EffectAllow},
+		{roles: nil, method: "GET", path: "/api/v1/users/1", effect: EffectDeny},
+		{roles: []string{"admin"}, method: "GET", path: "/api/v1/orders", effect: EffectNotApplicable},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.effect, engine.Authorize(tt.roles, tt.method, tt.path).Effect, "%v %s %s", tt.roles, tt.method, tt.path)
+	}
+
+	assert.Equal(t, []string{"admin", "viewer"}, engine.Roles(map[string]interface{}{"roles": []interface{}{"admin", "viewer"}}))
+}
+
+func TestEngine_Reload(t *testing.T) {
+	logger.InitLog("test")
+
+	path := filepath.Join(t.TempDir(), "policy.json")
+	writePolicy(t, path, Policy{Roles: []Role{{Name: "viewer", Permissions: []Permission{{Method: "GET", Path: "/orders"}}}}})
+
+	engine := NewEngine(NewFileSource(path), WithReloadInterval(10*time.Millisecond))
+	require.NoError(t, engine.Start(context.Background()))
+	defer engine.Stop()
+
+	assert.True(t, engine.Authorize([]string{"viewer"}, "GET", "/orders").Allowed())
+
+	writePolicy(t, path, Policy{Roles: []Role{{Name: "viewer", Permissions: []Permission{{Method: "GET", Path: "/users"}}}}})
+	assert.Eventually(t, func() bool {
+		return engine.Authorize([]string{"viewer"}, "GET", "/orders").Effect == EffectNotApplicable
+	}, time.Second, 10*time.Millisecond)
+
+	// an invalid policy keeps the previous one
+	writePolicy(t, path, Policy{Roles: []Role{{Name: "viewer", Inherits: []string{"unknown"}}}})
+	assert.Error(t, engine.Load(context.Background()))
+	assert.True(t, engine.Authorize([]string{"viewer"}, "GET", "/users").Allowed())
+}
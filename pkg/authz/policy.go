@@ -0,0 +1,118 @@
+package authz
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"go-source/pkg/database/mongodb"
+	"os"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	collectionRole = "authz_roles"
+
+	// MethodAny matches every HTTP method.
+	MethodAny = "*"
+	// RolePublic is granted to every request, its permissions need no role.
+	RolePublic = "public"
+)
+
+var ErrNilCollection = errors.New("authz: mongo collection is nil")
+
+// Permission allows a method on the paths matching Path, a utils.APIPathMatch
+// pattern such as /api/v1/users/:id.
+type Permission struct {
+	Method string `json:"method" bson:"method"`
+	Path   string `json:"path" bson:"path"`
+}
+
+type Role struct {
+	Name        string       `json:"name" bson:"_id"`
+	Permissions []Permission `json:"permissions" bson:"permissions"`
+	// Inherits grants the permissions of other roles, e.g. admin inherits editor
+	Inherits []string `json:"inherits,omitempty" bson:"inherits,omitempty"`
+}
+
+func (Role) CollectionName() string {
+	return collectionRole
+}
+
+func (Role) IndexModels() []mongo.IndexModel {
+	return nil
+}
+
+type Policy struct {
+	Roles []Role `json:"roles"`
+}
+
+// PolicySource loads the policy, called again on every reload.
+type PolicySource interface {
+	Load(ctx context.Context) (*Policy, error)
+}
+
+type staticSource struct {
+	policy *Policy
+}
+
+// NewStaticSource serves a policy built in code.
+func NewStaticSource(policy *Policy) PolicySource {
+	return &staticSource{policy: policy}
+}
+
+func (s *staticSource) Load(_ context.Context) (*Policy, error) {
+	return s.policy, nil
+}
+
+type fileSource struct {
+	path string
+}
+
+// NewFileSource loads the policy from a JSON file, e.g. mounted from a config map.
+func NewFileSource(path string) PolicySource {
+	return &fileSource{path: path}
+}
+
+func (s *fileSource) Load(_ context.Context) (*Policy, error) {
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return nil, err
+	}
+
+	var policy Policy
+	if err := json.Unmarshal(data, &policy); err != nil {
+		return nil, err
+	}
+
+	return &policy, nil
+}
+
+type mongoSource struct {
+	collection *mongo.Collection
+}
+
+// NewMongoSource loads the roles from the authz_roles collection, one document per role.
+func NewMongoSource(db *mongodb.DatabaseStorage) PolicySource {
+	repo := mongodb.NewRepository[Role](db)
+	return &mongoSource{collection: repo.Collection}
+}
+
+func (s *mongoSource) Load(ctx context.Context) (*Policy, error) {
+	if s.collection == nil {
+		return nil, ErrNilCollection
+	}
+
+	cursor, err := s.collection.Find(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+
+	var roles []Role
+	if err := cursor.All(ctx, &roles); err != nil {
+		return nil, err
+	}
+
+	return &Policy{Roles: roles}, nil
+}
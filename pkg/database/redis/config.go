@@ -1,7 +1,40 @@
 package redis
 
+import "time"
+
 type RedisConfig struct {
-	Addr     string `env:"ADDRESS,required,notEmpty"`
-	Password string `env:"PASS,required,notEmpty"`
-	User     string `env:"USER"`
+	// Addr is the standalone address, Addrs the seed nodes of a cluster or the sentinels.
+	Addr     string   `env:"ADDRESS"`
+	Addrs    []string `env:"ADDRESSES" envSeparator:","`
+	Password string   `env:"PASS,required,notEmpty"`
+	User     string   `env:"USER"`
+	DB       int      `env:"DB"`
+
+	// MasterName enables sentinel mode.
+	MasterName       string `env:"MASTER_NAME"`
+	SentinelUser     string `env:"SENTINEL_USER"`
+	SentinelPassword string `env:"SENTINEL_PASS"`
+
+	ClusterMode bool `env:"CLUSTER_MODE"`
+
+	TLSEnabled            bool   `env:"TLS_ENABLED"`
+	TLSCACert             string `env:"TLS_CA_CERT"` // PEM content
+	TLSCAFile             string `env:"TLS_CA_FILE"`
+	TLSServerName         string `env:"TLS_SERVER_NAME"`
+	TLSInsecureSkipVerify bool   `env:"TLS_INSECURE_SKIP_VERIFY"`
+
+	PoolSize        int           `env:"POOL_SIZE"`
+	MinIdleConns    int           `env:"MIN_IDLE_CONNS"`
+	PoolTimeout     time.Duration `env:"POOL_TIMEOUT"`
+	DialTimeout     time.Duration `env:"DIAL_TIMEOUT"`
+	ReadTimeout     time.Duration `env:"READ_TIMEOUT"`
+	WriteTimeout    time.Duration `env:"WRITE_TIMEOUT"`
+	ConnMaxIdleTime time.Duration `env:"CONN_MAX_IDLE_TIME"`
+
+	// FailFast makes ConnectRedis return the ping error instead of a client that
+	// reconnects once Redis is reachable.
+	FailFast bool `env:"FAIL_FAST"`
+
+	SlowThreshold time.Duration `env:"SLOW_THRESHOLD"`
+	StatsInterval time.Duration `env:"STATS_INTERVAL"`
 }
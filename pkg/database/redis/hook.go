@@ -0,0 +1,139 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	logger "go-source/pkg/log"
+	"go-source/pkg/metric"
+	"net"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	meter "go.opentelemetry.io/otel/metric"
+)
+
+const (
+	codeSuccess = "SUCCESS"
+	codeTimeout = "TIMEOUT"
+
+	methodPipeline = "pipeline"
+)
+
+// metricHook records latency and errors of every command and logs slow ones.
+type metricHook struct {
+	slowThreshold time.Duration
+}
+
+func newMetricHook(slowThreshold time.Duration) *metricHook {
+	return &metricHook{slowThreshold: slowThreshold}
+}
+
+func (h *metricHook) DialHook(next redis.DialHook) redis.DialHook {
+	return next
+}
+
+func (h *metricHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		start := time.Now()
+		err := next(ctx, cmd)
+		h.record(ctx, cmd.FullName(), time.Since(start), err, cmd)
+		return err
+	}
+}
+
+func (h *metricHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		start := time.Now()
+		err := next(ctx, cmds)
+		h.record(ctx, methodPipeline, time.Since(start), err, cmds...)
+		return err
+	}
+}
+
+func (h *metricHook) record(ctx context.Context, method string, duration time.Duration, err error, cmds ...redis.Cmder) {
+	code := codeSuccess
+	if err != nil && !errors.Is(err, redis.Nil) {
+		code = metric.DefaultErr.Error()
+
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			code = codeTimeout
+		}
+
+		metric.NewRedisErrorCounter(method, code)
+	}
+
+	metric.NewRedisHistogramDuration(method, code, duration)
+
+	if h.slowThreshold > 0 && duration >= h.slowThreshold {
+		log := logger.GetLogger().AddTraceInfoContextRequest(ctx)
+
+		args := make([]interface{}, 0, len(cmds))
+		for _, cmd := range cmds {
+			// only the command name and key, values may hold sensitive data
+			cmdArgs := cmd.Args()
+			if len(cmdArgs) > 2 {
+				cmdArgs = cmdArgs[:2]
+			}
+			args = append(args, cmdArgs)
+		}
+
+		log.Warn().
+			Str("method", method).
+			Interface("args", args).
+			Str("latency", duration.String()).
+			Str("code", code).
+			Msg("redis slow command")
+	}
+}
+
+// collectPoolStats exports the pool stats as gauges every interval until ctx is done.
+func (c *Client) collectPoolStats(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			c.recordPoolStats(ctx)
+		}
+	}
+}
+
+func (c *Client) recordPoolStats(ctx context.Context) {
+	if c.client == nil {
+		return
+	}
+
+	stats := c.client.PoolStats()
+	values := map[string]uint32{
+		"hits":        stats.Hits,
+		"misses":      stats.Misses,
+		"timeouts":    stats.Timeouts,
+		"total_conns": stats.TotalConns,
+		"idle_conns":  stats.IdleConns,
+		"stale_conns": stats.StaleConns,
+	}
+
+	for stat, value := range values {
+		label := metric.NewLabel(
+			metric.WithComponent(metric.RedisComponent),
+			metric.WithAttributes(metric.NewTags(metric.StatAttr, stat)),
+		)
+		metric.RedisPoolGauge.Record(ctx, int64(value), meter.WithAttributes(label.GetAttributes()...))
+	}
+}
+
+// HealthCheck pings Redis, it is meant to be used as a health probe.
+func (c *Client) HealthCheck(ctx context.Context) error {
+	if c == nil || c.client == nil {
+		return errors.New("redis client is nil")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+
+	return c.client.Ping(ctx).Err()
+}
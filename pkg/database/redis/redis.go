@@ -2,9 +2,13 @@ package redis
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
 	"errors"
+	"fmt"
 	logger "go-source/pkg/log"
+	"os"
 	"reflect"
 	"sync"
 	"time"
@@ -15,8 +19,10 @@ import (
 )
 
 const (
-	expDefault      = 60 * time.Second
-	expMutexDefault = 10 * time.Second
+	expDefault           = 60 * time.Second
+	expMutexDefault      = 10 * time.Second
+	healthCheckTimeout   = 2 * time.Second
+	statsIntervalDefault = 15 * time.Second
 )
 
 type Client struct {
@@ -28,61 +34,157 @@ type Client struct {
 
 var (
 	instanceRedisClient *Client
-	onceRedisClient     sync.Once
+	muRedisClient       sync.Mutex
 )
 
+// ConnectRedis connects the shared client once. When the ping fails the error is
+// logged and the client is still returned, it redials on the next command. With
+// FailFast the ping error is returned instead and the next call connects again.
 func ConnectRedis(ctx context.Context, cfg *RedisConfig) (*Client, error) {
 	log := logger.GetLogger()
 
+	muRedisClient.Lock()
+	defer muRedisClient.Unlock()
+
 	if instanceRedisClient != nil {
-		_, err := instanceRedisClient.client.Ping(ctx).Result()
-		if err == nil {
-			return instanceRedisClient, nil
+		return instanceRedisClient, nil
+	}
+
+	redisClient, err := newUniversalClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = redisClient.Ping(ctx).Result()
+	if err != nil {
+		log.Error().Err(err).Msg("ping redis failed")
+		if cfg.FailFast {
+			_ = redisClient.Close()
+			return nil, fmt.Errorf("ping redis: %w", err)
 		}
 	}
 
-	onceRedisClient.Do(func() {
-		redisClient := redis.NewClient(&redis.Options{
-			Addr:     cfg.Addr,
-			DB:       0,
-			Password: cfg.Password,
-			Username: cfg.User,
-		})
+	redisClient.AddHook(newMetricHook(cfg.SlowThreshold))
+
+	// The pool wraps the universal client, so locks follow the same topology:
+	// slot routing in cluster mode and master failover with sentinel.
+	pool := goredis.NewPool(redisClient)
+	// Create an instance of redisync to be used to obtain a mutual exclusion lock.
+	redisRedsync := redsync.New(pool)
 
-		_, err := redisClient.Ping(ctx).Result()
+	if err == nil {
+		log.Info().Msg("connect redis successfully")
+	}
+
+	instanceRedisClient = &Client{
+		client:          redisClient,
+		redSync:         redisRedsync,
+		expDefault:      expDefault,
+		expMutexDefault: expMutexDefault,
+	}
+
+	statsInterval := cfg.StatsInterval
+	if statsInterval <= 0 {
+		statsInterval = statsIntervalDefault
+	}
+	go instanceRedisClient.collectPoolStats(ctx, statsInterval)
+
+	return instanceRedisClient, nil
+}
+
+// newUniversalClient picks the topology from the config: cluster when ClusterMode
+// is set, sentinel when MasterName is set, otherwise a single node.
+func newUniversalClient(cfg *RedisConfig) (redis.UniversalClient, error) {
+	addrs := cfg.Addrs
+	if len(addrs) == 0 && cfg.Addr != "" {
+		addrs = []string{cfg.Addr}
+	}
+	if len(addrs) == 0 {
+		return nil, errors.New("redis address is empty")
+	}
+
+	opts := &redis.UniversalOptions{
+		Addrs:            addrs,
+		DB:               cfg.DB,
+		Username:         cfg.User,
+		Password:         cfg.Password,
+		SentinelUsername: cfg.SentinelUser,
+		SentinelPassword: cfg.SentinelPassword,
+		MasterName:       cfg.MasterName,
+		PoolSize:         cfg.PoolSize,
+		MinIdleConns:     cfg.MinIdleConns,
+		PoolTimeout:      cfg.PoolTimeout,
+		DialTimeout:      cfg.DialTimeout,
+		ReadTimeout:      cfg.ReadTimeout,
+		WriteTimeout:     cfg.WriteTimeout,
+		ConnMaxIdleTime:  cfg.ConnMaxIdleTime,
+	}
+
+	if cfg.TLSEnabled {
+		tlsConfig, err := buildTLSConfig(cfg)
 		if err != nil {
-			log.Error().Err(err).Msg("ping redis failed")
-			instanceRedisClient = &Client{
-				client:          nil,
-				redSync:         nil,
-				expDefault:      0,
-				expMutexDefault: 0,
-			}
-			return
+			return nil, err
 		}
+		opts.TLSConfig = tlsConfig
+	}
 
-		pool := goredis.NewPool(redisClient)
-		// Create an instance of redisync to be used to obtain a mutual exclusion lock.
-		redisRedsync := redsync.New(pool)
+	switch {
+	case cfg.ClusterMode:
+		if cfg.DB != 0 {
+			return nil, errors.New("redis cluster does not support db selection")
+		}
+		return redis.NewClusterClient(opts.Cluster()), nil
 
-		log.Info().Msg("connect redis successfully")
+	case cfg.MasterName != "":
+		return redis.NewFailoverClient(opts.Failover()), nil
 
-		instanceRedisClient = &Client{
-			client:          redisClient,
-			redSync:         redisRedsync,
-			expDefault:      expDefault,
-			expMutexDefault: expMutexDefault,
+	default:
+		if len(addrs) > 1 {
+			return nil, errors.New("redis multiple addresses require cluster mode or sentinel master name")
 		}
-	})
+		return redis.NewClient(opts.Simple()), nil
+	}
+}
 
-	return instanceRedisClient, nil
+func buildTLSConfig(cfg *RedisConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         cfg.TLSServerName,
+		InsecureSkipVerify: cfg.TLSInsecureSkipVerify,
+	}
+
+	caCert := []byte(cfg.TLSCACert)
+	if len(caCert) == 0 && cfg.TLSCAFile != "" {
+		data, err := os.ReadFile(cfg.TLSCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("read redis ca file: %w", err)
+		}
+		caCert = data
+	}
+
+	if len(caCert) > 0 {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caCert) {
+			return nil, errors.New("redis ca cert invalid")
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	return tlsConfig, nil
 }
 
+// GetInstance returns the shared client, nil until ConnectRedis is called.
 func GetInstance() *Client {
+	muRedisClient.Lock()
+	defer muRedisClient.Unlock()
+
 	return instanceRedisClient
 }
 
 func (c *Client) GetClient() redis.UniversalClient {
+	if c == nil {
+		return nil
+	}
 	return c.client
 }
 
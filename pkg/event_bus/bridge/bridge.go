@@ -0,0 +1,194 @@
+package bridge
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-source/pkg/event_bus"
+	logger "go-source/pkg/log"
+	"go-source/pkg/queue/event"
+	"go-source/pkg/queue/kafka"
+	"go-source/pkg/utils"
+	"slices"
+	"sync"
+)
+
+const subscriberName = "kafka-bridge"
+
+var (
+	ErrEmptySource = errors.New("bridge: source is empty")
+	ErrNilRegistry = errors.New("bridge: registry is nil")
+)
+
+// Keyer is implemented by event data that sets the Kafka key of the message.
+type Keyer interface {
+	EventKey() string
+}
+
+type decodeFunc func(data json.RawMessage) (interface{}, error)
+
+type typeVersion struct {
+	eventType string
+	version   int
+}
+
+type fromKafkaKey struct{}
+
+// Bridge forwards the outbound event types of the bus to their Kafka topic, and
+// publishes on the bus the inbound events read from Kafka. Events are sent as an
+// event.Envelope whose data is the JSON of Event.Data.
+type Bridge struct {
+	bus      *event_bus.EventBus
+	producer kafka.ProducerInterface
+	registry *event.Registry
+	cfg      BridgeConfig
+	// instance tells the events of this bridge from the ones of the other replicas
+	instance string
+	decoders map[typeVersion]decodeFunc
+	mu       sync.RWMutex
+}
+
+// NewBridge creates a bridge, the outbound event data must be registered in registry,
+// which sets the type and version of their envelope.
+func NewBridge(bus *event_bus.EventBus, producer kafka.ProducerInterface, registry *event.Registry, cfg BridgeConfig) (*Bridge, error) {
+	if cfg.Source == "" {
+		return nil, ErrEmptySource
+	}
+	if registry == nil {
+		return nil, ErrNilRegistry
+	}
+
+	return &Bridge{
+		bus:      bus,
+		producer: producer,
+		registry: registry,
+		cfg:      cfg,
+		instance: utils.RandString(),
+		decoders: make(map[typeVersion]decodeFunc),
+	}, nil
+}
+
+// RegisterInbound decodes the data of the inbound events of eventType and version
+// into a T, so their subscribers get a T as Event.Data instead of a json.RawMessage.
+// Once a type has a decoder, its events of the versions without one are rejected.
+func RegisterInbound[T any](b *Bridge, eventType string, version int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.decoders[typeVersion{eventType: eventType, version: version}] = func(data json.RawMessage) (interface{}, error) {
+		var v T
+		if err := json.Unmarshal(data, &v); err != nil {
+			return nil, err
+		}
+		return v, nil
+	}
+}
+
+// Start subscribes the bridge to the outbound event types.
+func (b *Bridge) Start() error {
+	for eventType, topic := range b.cfg.Outbound {
+		if err := b.bus.SubscribeWithName(eventType, subscriberName, b.forward(eventType, topic)); err != nil {
+			return fmt.Errorf("bridge subscribe %s: %w", eventType, err)
+		}
+	}
+	return nil
+}
+
+// Stop unsubscribes the bridge from the outbound event types.
+func (b *Bridge) Stop() {
+	for eventType := range b.cfg.Outbound {
+		_ = b.bus.Unsubscribe(eventType, subscriberName)
+	}
+}
+
+// InboundTopics returns the topics the consumer of OnEvent must read.
+func (b *Bridge) InboundTopics() []string {
+	var topics []string
+	for _, topic := range b.cfg.Inbound {
+		if !slices.Contains(topics, topic) {
+			topics = append(topics, topic)
+		}
+	}
+	slices.Sort(topics)
+	return topics
+}
+
+func (b *Bridge) forward(eventType, topic string) event_bus.HandleEvent {
+	return func(evt *event_bus.Event) error {
+		// the event came from Kafka, sending it back would loop
+		if fromKafka, _ := evt.Ctx.Value(fromKafkaKey{}).(bool); fromKafka {
+			return nil
+		}
+
+		env, err := b.registry.NewEnvelope(evt.Ctx, b.cfg.Source, evt.Data)
+		if err != nil {
+			return fmt.Errorf("bridge envelope %s: %w", eventType, err)
+		}
+		env.Instance = b.instance
+
+		var key string
+		if keyer, ok := evt.Data.(Keyer); ok {
+			key = keyer.EventKey()
+		}
+
+		return b.producer.PublishWithTopic(evt.Ctx, topic, key, env)
+	}
+}
+
+// OnEvent is a kafka.OnEventHandler publishing the inbound events on the bus. It
+// waits for the subscribers, so a failed event is retried by the consumer.
+func (b *Bridge) OnEvent(ctx context.Context, key, value []byte) error {
+	var env event.Envelope
+	if err := json.Unmarshal(value, &env); err != nil {
+		return kafka.NonRetryable(fmt.Errorf("bridge decode envelope: %w", err))
+	}
+
+	log := logger.GetLogger().AddTraceInfoContextRequest(ctx)
+	if env.Instance == b.instance {
+		log.Debug().Msgf("bridge skipped own event: type=%s id=%s", env.Type, env.ID)
+		return nil
+	}
+	// the topic is checked when the consumer sets it, see kafka.TopicFromContext
+	topic, ok := b.cfg.Inbound[env.Type]
+	if source := kafka.TopicFromContext(ctx); !ok || (source != "" && source != topic) {
+		log.Debug().Msgf("bridge skipped event not inbound: type=%s topic=%s id=%s", env.Type, source, env.ID)
+		return nil
+	}
+
+	var data interface{} = env.Data
+	b.mu.RLock()
+	decode, ok := b.decoders[typeVersion{eventType: env.Type, version: env.Version}]
+	typed := ok || b.hasDecoder(env.Type)
+	b.mu.RUnlock()
+	if ok {
+		var err error
+		if data, err = decode(env.Data); err != nil {
+			return kafka.NonRetryable(fmt.Errorf("bridge decode %s v%d: %w", env.Type, env.Version, err))
+		}
+	} else if typed {
+		// the subscribers expect the registered type, not the raw data of another version
+		return kafka.NonRetryable(fmt.Errorf("bridge decode %s v%d: version not registered", env.Type, env.Version))
+	}
+
+	if env.Trace != nil {
+		ctx = context.WithValue(ctx, utils.KeyTraceInfo, *env.Trace)
+	}
+	ctx = context.WithValue(ctx, fromKafkaKey{}, true)
+
+	err := b.bus.Publish(env.Type, &event_bus.Event{Ctx: ctx, Data: data})
+	if errors.Is(err, event_bus.ErrNoSubscriber) {
+		return nil
+	}
+	return err
+}
+
+// hasDecoder tells whether a version of eventType has a decoder, b.mu must be held.
+func (b *Bridge) hasDecoder(eventType string) bool {
+	for key := range b.decoders {
+		if key.eventType == eventType {
+			return true
+		}
+	}
+	return false
+}
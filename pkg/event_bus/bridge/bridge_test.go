@@ -0,0 +1,193 @@
+package bridge
+
+import (
+	"context"
+	"encoding/json"
+	"go-source/pkg/event_bus"
+	logger "go-source/pkg/log"
+	"go-source/pkg/queue/event"
+	"go-source/pkg/queue/kafka"
+	"go-source/pkg/queue/kafka/kafkatest"
+	"go-source/pkg/utils"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type userCreated struct {
+	UserID string `json:"user_id"`
+}
+
+func (e userCreated) EventKey() string {
+	return e.UserID
+}
+
+func TestBridge(t *testing.T) {
+	logger.InitLog("test")
+
+	const (
+		eventType = "user.created"
+		topic     = "user-events"
+	)
+	broker := kafkatest.NewBroker()
+	registry := event.NewRegistry(nil)
+	require.NoError(t, event.Register[userCreated](registry, eventType, 2))
+
+	// service a forwards its events, and reads the topic too
+	busA := event_bus.New()
+	bridgeA, err := NewBridge(busA, broker.NewProducer(topic), registry, BridgeConfig{
+		Source:   "a",
+		Outbound: map[string]string{eventType: topic},
+		Inbound:  map[string]string{eventType: topic},
+	})
+	require.NoError(t, err)
+	require.NoError(t, bridgeA.Start())
+
+	// service b receives them
+	busB := event_bus.New()
+	bridgeB, err := NewBridge(busB, broker.NewProducer(topic), registry, BridgeConfig{
+		Source:  "b",
+		Inbound: map[string]string{eventType: topic},
+	})
+	require.NoError(t, err)
+	RegisterInbound[userCreated](bridgeB, eventType, 2)
+
+	received := make(chan *event_bus.Event, 1)
+	busB.Subscribe(eventType, func(evt *event_bus.Event) error {
+		received <- evt
+		return nil
+	})
+
+	var loopedBack int
+	busA.Subscribe(eventType, func(evt *event_bus.Event) error {
+		loopedBack++
+		return nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	for group, bridge := range map[string]*Bridge{"a": bridgeA, "b": bridgeB} {
+		consumer := broker.NewConsumer(group, bridge.InboundTopics())
+		consumer.OnEvent(bridge.OnEvent)
+		go func() {
+			_ = consumer.Start(ctx)
+		}()
+	}
+
+	pubCtx := context.WithValue(context.Background(), utils.KeyTraceInfo, utils.TraceInfo{RequestID: "req-1"})
+	require.NoError(t, busA.Publish(eventType, &event_bus.Event{Ctx: pubCtx, Data: userCreated{UserID: "1"}}))
+
+	select {
+	case evt := <-received:
+		assert.Equal(t, userCreated{UserID: "1"}, evt.Data)
+		assert.Equal(t, "req-1", utils.GetRequestIdByContext(evt.Ctx).RequestID)
+	case <-time.After(time.Second):
+		t.Fatal("event not received")
+	}
+
+	broker.WaitConsumed(t, "a", topic, time.Second)
+	msgs := broker.AssertPublished(t, topic, 1)
+	assert.Equal(t, []byte("1"), msgs[0].Key)
+	var env event.Envelope
+	require.NoError(t, json.Unmarshal(msgs[0].Value, &env))
+	assert.Equal(t, 2, env.Version)
+	// only the local publish, the event read back from Kafka was skipped
+	assert.Equal(t, 1, loopedBack)
+}
+
+func TestBridge_Replicas(t *testing.T) {
+	logger.InitLog("test")
+
+	const (
+		eventType = "user.created"
+		topic     = "user-events"
+	)
+	broker := kafkatest.NewBroker()
+	registry := event.NewRegistry(nil)
+	require.NoError(t, event.Register[userCreated](registry, eventType, 1))
+
+	// two replicas of service a, each reading the topic in its own group
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	buses := make([]*event_bus.EventBus, 2)
+	received := make([]chan *event_bus.Event, 2)
+	for i, group := range []string{"a-1", "a-2"} {
+		buses[i] = event_bus.New()
+		bridge, err := NewBridge(buses[i], broker.NewProducer(topic), registry, BridgeConfig{
+			Source:   "a",
+			Outbound: map[string]string{eventType: topic},
+			Inbound:  map[string]string{eventType: topic},
+		})
+		require.NoError(t, err)
+		require.NoError(t, bridge.Start())
+		RegisterInbound[userCreated](bridge, eventType, 1)
+
+		received[i] = make(chan *event_bus.Event, 2)
+		ch := received[i]
+		buses[i].Subscribe(eventType, func(evt *event_bus.Event) error {
+			ch <- evt
+			return nil
+		})
+
+		consumer := broker.NewConsumer(group, bridge.InboundTopics())
+		consumer.OnEvent(bridge.OnEvent)
+		go func() {
+			_ = consumer.Start(ctx)
+		}()
+	}
+
+	require.NoError(t, buses[0].Publish(eventType, &event_bus.Event{Ctx: context.Background(), Data: userCreated{UserID: "1"}}))
+	<-received[0]
+
+	select {
+	case evt := <-received[1]:
+		assert.Equal(t, userCreated{UserID: "1"}, evt.Data)
+	case <-time.After(time.Second):
+		t.Fatal("event of the other replica not received")
+	}
+
+	broker.WaitConsumed(t, "a-1", topic, time.Second)
+	broker.WaitConsumed(t, "a-2", topic, time.Second)
+	// the replica skipped its own event and did not forward the received one
+	assert.Len(t, received[0], 0)
+	broker.AssertPublished(t, topic, 1)
+}
+
+func TestBridge_OnEventTopicAndVersion(t *testing.T) {
+	logger.InitLog("test")
+
+	const eventType = "user.created"
+	bus := event_bus.New()
+	bridge, err := NewBridge(bus, nil, event.NewRegistry(nil), BridgeConfig{
+		Source:  "b",
+		Inbound: map[string]string{eventType: "user-events"},
+	})
+	require.NoError(t, err)
+	RegisterInbound[userCreated](bridge, eventType, 1)
+
+	var received []interface{}
+	bus.Subscribe(eventType, func(evt *event_bus.Event) error {
+		received = append(received, evt.Data)
+		return nil
+	})
+
+	envelope := func(version int) []byte {
+		value, err := json.Marshal(event.Envelope{Type: eventType, Version: version, Source: "a", Data: json.RawMessage(`{"user_id":"1"}`)})
+		require.NoError(t, err)
+		return value
+	}
+
+	// the type is inbound from another topic
+	require.NoError(t, bridge.OnEvent(kafka.ContextWithTopic(context.Background(), "other-events"), nil, envelope(1)))
+	assert.Empty(t, received)
+
+	// a version without decoder is not handed as the registered type
+	err = bridge.OnEvent(kafka.ContextWithTopic(context.Background(), "user-events"), nil, envelope(2))
+	assert.ErrorIs(t, err, kafka.ErrNonRetryable)
+	assert.Empty(t, received)
+
+	require.NoError(t, bridge.OnEvent(kafka.ContextWithTopic(context.Background(), "user-events"), nil, envelope(1)))
+	assert.Equal(t, []interface{}{userCreated{UserID: "1"}}, received)
+}
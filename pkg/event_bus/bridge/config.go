@@ -0,0 +1,9 @@
+package bridge
+
+// BridgeConfig maps event types to Kafka topics, e.g. BRIDGE_OUTBOUND="user.created:user-events".
+type BridgeConfig struct {
+	// Source names this service in the forwarded events
+	Source   string            `env:"SOURCE"`
+	Outbound map[string]string `env:"OUTBOUND"`
+	Inbound  map[string]string `env:"INBOUND"`
+}
@@ -2,25 +2,54 @@ package event_bus
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	logger "go-source/pkg/log"
+	"go-source/pkg/utils"
 	"reflect"
+	"strconv"
 	"sync"
 )
 
+const (
+	anonymousPrefix = "anonymous-"
+	responderName   = "responder"
+)
+
+var (
+	ErrNoSubscriber       = errors.New("no subscriber found")
+	ErrSubscriberExisted  = errors.New("subscriber already existed")
+	ErrResponderExisted   = errors.New("responder already existed")
+	ErrResponseNotPointer = errors.New("response must be a pointer")
+	ErrBusClosed          = errors.New("event bus is closed")
+)
+
 type ErrCallback chan error
+
+// Deprecated: subscribers are internal to the bus, they each have a buffer and workers.
 type Subscriber chan *Event
+
 type HandleEvent func(event *Event) error
 
 type Event struct {
-	Ctx         context.Context
-	Data        interface{}
+	Ctx  context.Context
+	Data interface{}
+	// Deprecated: handler errors are returned by Publish and Request, ErrCallback is not used.
 	ErrCallback ErrCallback
-	Response    interface{}
+	// Response is filled by the responder of a request, it must be a pointer.
+	Response interface{}
 }
 
+// EventBus delivers every published event to all the subscribers of its type, each
+// with its own buffer and workers. Request/reply goes to the single responder of
+// the type instead.
 type EventBus struct {
-	subscriber map[string]Subscriber
-	mu         sync.RWMutex
+	subscribers map[string][]*subscriber
+	responders  map[string]*subscriber
+	middlewares []Middleware
+	seq         int
+	closed      bool
+	mu          sync.RWMutex
 }
 
 var (
@@ -28,92 +57,278 @@ var (
 	once     sync.Once
 )
 
+// NewEventBus returns the bus shared by the process.
 func NewEventBus() *EventBus {
 	once.Do(func() {
-		eventBus = &EventBus{
-			subscriber: make(map[string]Subscriber),
-		}
+		eventBus = New()
 	})
 	return eventBus
 }
 
+// New returns a bus of its own, e.g. for tests.
+func New() *EventBus {
+	return &EventBus{
+		subscribers: make(map[string][]*subscriber),
+		responders:  make(map[string]*subscriber),
+	}
+}
+
 func GetEventBus() *EventBus {
 	return eventBus
 }
 
-func (eb *EventBus) Subscribe(eventType string, fn HandleEvent) {
+// Use adds middlewares to the subscribers and responders created afterwards.
+func (eb *EventBus) Use(middlewares ...Middleware) {
+	eb.mu.Lock()
+	defer eb.mu.Unlock()
+
+	eb.middlewares = append(eb.middlewares, middlewares...)
+}
+
+// Subscribe adds an anonymous subscriber to eventType.
+func (eb *EventBus) Subscribe(eventType string, fn HandleEvent, opts ...SubscribeOption) {
 	eb.mu.Lock()
 	defer eb.mu.Unlock()
-	if _, ok := eb.subscriber[eventType]; !ok {
-		eb.subscriber[eventType] = make(Subscriber, 10)
+
+	if eb.closed {
+		logger.GetLogger().Warn().Msgf("event bus is closed, subscribe ignored: type=%s", eventType)
+		return
 	}
 
-	sub := eb.subscriber[eventType]
+	eb.seq++
+	name := anonymousPrefix + strconv.Itoa(eb.seq)
+	eb.subscribers[eventType] = append(eb.subscribers[eventType], eb.newSubscriber(eventType, name, fn, opts...))
+}
 
-	go func(sub Subscriber) {
-		for event := range sub {
-			select {
-			case <-event.Ctx.Done():
-			default:
-				sendErr(event.ErrCallback, fn(event))
-			}
-		}
-	}(sub)
+func (eb *EventBus) newSubscriber(eventType, name string, fn HandleEvent, opts ...SubscribeOption) *subscriber {
+	info := SubscriberInfo{EventType: eventType, Name: name}
+	return newSubscriber(info, fn, eb.middlewares, opts...)
 }
 
-func (eb *EventBus) Publish(eventType string, event *Event) error {
-	if event.ErrCallback == nil {
-		event.ErrCallback = make(ErrCallback)
+// SubscribeWithName adds a subscriber to eventType, name is used to unsubscribe it.
+func (eb *EventBus) SubscribeWithName(eventType, name string, fn HandleEvent, opts ...SubscribeOption) error {
+	eb.mu.Lock()
+	defer eb.mu.Unlock()
+
+	if eb.closed {
+		return ErrBusClosed
+	}
+	if eb.findSubscriber(eventType, name) >= 0 {
+		return fmt.Errorf("%w: %s %s", ErrSubscriberExisted, eventType, name)
 	}
 
-	if event.Response != nil {
-		v := reflect.ValueOf(event.Response)
-		if v.Kind() != reflect.Ptr {
-			close(event.ErrCallback)
-			return fmt.Errorf("response must be a pointer")
+	eb.subscribers[eventType] = append(eb.subscribers[eventType], eb.newSubscriber(eventType, name, fn, opts...))
+	return nil
+}
+
+// Unsubscribe removes the subscriber, after it handled the events already queued.
+func (eb *EventBus) Unsubscribe(eventType, name string) error {
+	eb.mu.Lock()
+	i := eb.findSubscriber(eventType, name)
+	if i < 0 {
+		eb.mu.Unlock()
+		return fmt.Errorf("%w: %s %s", ErrNoSubscriber, eventType, name)
+	}
+
+	sub := eb.subscribers[eventType][i]
+	subs := append([]*subscriber{}, eb.subscribers[eventType][:i]...)
+	eb.subscribers[eventType] = append(subs, eb.subscribers[eventType][i+1:]...)
+	if len(eb.subscribers[eventType]) == 0 {
+		delete(eb.subscribers, eventType)
+	}
+	eb.mu.Unlock()
+
+	sub.stop()
+	return nil
+}
+
+func (eb *EventBus) findSubscriber(eventType, name string) int {
+	for i, sub := range eb.subscribers[eventType] {
+		if sub.info.Name == name {
+			return i
 		}
 	}
+	return -1
+}
+
+// Reply sets the responder of the requests of eventType, there is one per type.
+func (eb *EventBus) Reply(eventType string, fn HandleEvent, opts ...SubscribeOption) error {
+	eb.mu.Lock()
+	defer eb.mu.Unlock()
+
+	if eb.closed {
+		return ErrBusClosed
+	}
+	if _, ok := eb.responders[eventType]; ok {
+		return fmt.Errorf("%w: %s", ErrResponderExisted, eventType)
+	}
+
+	eb.responders[eventType] = eb.newSubscriber(eventType, responderName, fn, opts...)
+	return nil
+}
+
+// StopReply removes the responder of eventType.
+func (eb *EventBus) StopReply(eventType string) error {
+	eb.mu.Lock()
+	responder, ok := eb.responders[eventType]
+	delete(eb.responders, eventType)
+	eb.mu.Unlock()
 
-	if err := eb.publish(eventType, event); err != nil {
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrNoSubscriber, eventType)
+	}
+
+	responder.stop()
+	return nil
+}
+
+// Request sends event to the responder of eventType and waits for its reply, the
+// responder fills event.Response.
+func (eb *EventBus) Request(eventType string, event *Event) error {
+	if event.Response != nil && reflect.ValueOf(event.Response).Kind() != reflect.Ptr {
+		return ErrResponseNotPointer
+	}
+
+	d := newDelivery(event, true)
+
+	eb.mu.RLock()
+	if eb.closed {
+		eb.mu.RUnlock()
+		return ErrBusClosed
+	}
+	responder, ok := eb.responders[eventType]
+	eb.mu.RUnlock()
+	if !ok {
+		return fmt.Errorf("%w: event type %s", ErrNoSubscriber, eventType)
+	}
+
+	// a full buffer blocks enqueue, the bus stays unlocked meanwhile
+	if err := responder.enqueue(event.Ctx, d); err != nil {
 		return err
 	}
+	return wait(event.Ctx, d)
+}
 
-	defer close(event.ErrCallback)
+// Publish sends event to the subscribers of eventType and waits for all of them,
+// the errors they return are combined. It never goes to the responder of eventType,
+// requests are sent with Request.
+func (eb *EventBus) Publish(eventType string, event *Event) error {
+	if event.Response != nil && reflect.ValueOf(event.Response).Kind() != reflect.Ptr {
+		return ErrResponseNotPointer
+	}
 
-	// Wait for the ErrCallback
-	select {
-	case <-event.Ctx.Done():
-		return fmt.Errorf("%w: no ErrCallback received", event.Ctx.Err())
-	case err := <-event.ErrCallback:
+	deliveries, errs, err := eb.publish(eventType, event, true)
+	if err != nil {
+		return err
+	}
+
+	for _, d := range deliveries {
+		if err = wait(event.Ctx, d); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return utils.CombineErrors(errs)
+}
+
+// PublishAsync sends event to the subscribers of eventType without waiting for them.
+// The handlers get event.Ctx without its cancellation, event.Ctx only bounds the wait
+// for buffer room. It returns the errors of the subscribers that could not queue the event.
+func (eb *EventBus) PublishAsync(eventType string, event *Event) error {
+	_, errs, err := eb.publish(eventType, event, false)
+	if err != nil {
 		return err
 	}
+	return utils.CombineErrors(errs)
 }
 
-func (eb *EventBus) publish(eventType string, event *Event) error {
+// publish queues event to each subscriber, it returns the queued deliveries and the
+// errors of the subscribers that could not queue it.
+func (eb *EventBus) publish(eventType string, event *Event, waitHandlers bool) ([]*delivery, []error, error) {
 	eb.mu.RLock()
-	defer eb.mu.RUnlock()
+	if eb.closed {
+		eb.mu.RUnlock()
+		return nil, nil, ErrBusClosed
+	}
+	// subscribing appends and unsubscribing copies, the snapshot is safe to range unlocked
+	subs := eb.subscribers[eventType]
+	_, hasResponder := eb.responders[eventType]
+	eb.mu.RUnlock()
 
-	sub, ok := eb.subscriber[eventType]
-	if !ok {
-		return fmt.Errorf("no subscriber found for event type %s", eventType)
+	if len(subs) == 0 {
+		if hasResponder {
+			return nil, nil, fmt.Errorf("%w: event type %s has only a responder, use Request", ErrNoSubscriber, eventType)
+		}
+		return nil, nil, fmt.Errorf("%w: event type %s", ErrNoSubscriber, eventType)
+	}
+
+	if event.Ctx == nil {
+		event.Ctx = context.Background()
+	}
+	ctx := event.Ctx
+	if !waitHandlers {
+		// nobody waits for the handlers, they must outlive e.g. the request that published
+		detached := *event
+		detached.Ctx = context.WithoutCancel(ctx)
+		event = &detached
 	}
 
-	// Publish event to subscriber
-	sub <- event
+	var errs []error
+	deliveries := make([]*delivery, 0, len(subs))
+	for _, sub := range subs {
+		d := newDelivery(event, waitHandlers)
+		if err := sub.enqueue(ctx, d); err != nil {
+			errs = append(errs, fmt.Errorf("subscriber %s: %w", sub.info.Name, err))
+			continue
+		}
+		deliveries = append(deliveries, d)
+	}
 
-	return nil
+	return deliveries, errs, nil
 }
 
-// Safe function to send errors into ErrCallback, checks if channel is open
-func sendErr(ch ErrCallback, err error) {
-	defer func() {
-		if r := recover(); r != nil {
-			fmt.Printf("Recovered from panic: %v\n", r)
+// Close stops accepting events and waits, until ctx is done, for the subscribers and
+// responders to handle the events already queued. Publishes still blocked on a full
+// buffer fail with ErrSubscriberStopped.
+func (eb *EventBus) Close(ctx context.Context) error {
+	eb.mu.Lock()
+	if eb.closed {
+		eb.mu.Unlock()
+		return nil
+	}
+	eb.closed = true
+
+	var subs []*subscriber
+	for _, typeSubs := range eb.subscribers {
+		subs = append(subs, typeSubs...)
+	}
+	for _, responder := range eb.responders {
+		subs = append(subs, responder)
+	}
+	eb.subscribers = make(map[string][]*subscriber)
+	eb.responders = make(map[string]*subscriber)
+	eb.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for _, sub := range subs {
+			sub.stop()
 		}
 	}()
+
 	select {
-	case ch <- err:
-	default:
-		// If channel is already closed or no receivers, ignore
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("event bus close: %w", ctx.Err())
+	}
+}
+
+func wait(ctx context.Context, d *delivery) error {
+	select {
+	case <-ctx.Done():
+		return fmt.Errorf("%w: no ErrCallback received", ctx.Err())
+	case err := <-d.done:
+		return err
 	}
 }
@@ -0,0 +1,230 @@
+package event_bus
+
+import (
+	"context"
+	"errors"
+	logger "go-source/pkg/log"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestBus() *EventBus {
+	logger.InitLog("test")
+	return New()
+}
+
+func TestEventBus_FanOut(t *testing.T) {
+	eb := newTestBus()
+
+	var a, b atomic.Int32
+	eb.Subscribe("created", func(event *Event) error {
+		a.Add(1)
+		return nil
+	})
+	require.NoError(t, eb.SubscribeWithName("created", "b", func(event *Event) error {
+		b.Add(1)
+		return errors.New("b failed")
+	}, WithConcurrency(2)))
+
+	err := eb.Publish("created", &Event{Ctx: context.Background()})
+	assert.EqualError(t, err, "b failed")
+	assert.Equal(t, int32(1), a.Load())
+	assert.Equal(t, int32(1), b.Load())
+
+	require.NoError(t, eb.PublishAsync("created", &Event{Ctx: context.Background()}))
+	assert.Eventually(t, func() bool { return a.Load() == 2 && b.Load() == 2 }, time.Second, time.Millisecond)
+
+	require.NoError(t, eb.Unsubscribe("created", "b"))
+	require.NoError(t, eb.Publish("created", &Event{Ctx: context.Background()}))
+	assert.Equal(t, int32(2), b.Load())
+}
+
+func TestEventBus_Request(t *testing.T) {
+	eb := newTestBus()
+
+	require.NoError(t, eb.Reply("get", func(event *Event) error {
+		*event.Response.(*string) = "pong " + event.Data.(string)
+		return nil
+	}))
+	assert.ErrorIs(t, eb.Reply("get", func(event *Event) error { return nil }), ErrResponderExisted)
+
+	var resp string
+	require.NoError(t, eb.Request("get", &Event{Ctx: context.Background(), Data: "ping", Response: &resp}))
+	assert.Equal(t, "pong ping", resp)
+
+	assert.ErrorIs(t, eb.Request("unknown", &Event{Ctx: context.Background()}), ErrNoSubscriber)
+
+	// publishing fans out to the subscribers only, never to the responder
+	assert.ErrorIs(t, eb.Publish("get", &Event{Ctx: context.Background(), Data: "ping", Response: &resp}), ErrNoSubscriber)
+
+	var published atomic.Int32
+	eb.Subscribe("get", func(event *Event) error {
+		published.Add(1)
+		return nil
+	})
+	require.NoError(t, eb.Publish("get", &Event{Ctx: context.Background(), Data: "ping"}))
+	assert.Equal(t, int32(1), published.Load())
+}
+
+func TestEventBus_PanicAndTimeout(t *testing.T) {
+	eb := newTestBus()
+
+	eb.Subscribe("panic", func(event *Event) error {
+		panic("boom")
+	})
+	assert.ErrorIs(t, eb.Publish("panic", &Event{Ctx: context.Background()}), ErrHandlerPanic)
+	// the worker survived the panic
+	assert.ErrorIs(t, eb.Publish("panic", &Event{Ctx: context.Background()}), ErrHandlerPanic)
+
+	eb.Subscribe("slow", func(event *Event) error {
+		<-event.Ctx.Done()
+		return nil
+	}, WithMiddlewares(Timeout(10*time.Millisecond)))
+	assert.ErrorIs(t, eb.Publish("slow", &Event{Ctx: context.Background()}), ErrHandlerTimeout)
+}
+
+func TestEventBus_TimeoutLateResponse(t *testing.T) {
+	eb := newTestBus()
+
+	release, finished := make(chan struct{}), make(chan struct{})
+	require.NoError(t, eb.Reply("slow", func(event *Event) error {
+		defer close(finished)
+		// ignores its ctx and writes the response after the timeout
+		<-release
+		*event.Response.(*string) = "late"
+		return nil
+	}, WithMiddlewares(Timeout(10*time.Millisecond))))
+
+	resp := "initial"
+	assert.ErrorIs(t, eb.Request("slow", &Event{Ctx: context.Background(), Response: &resp}), ErrHandlerTimeout)
+
+	close(release)
+	// the caller reads resp while the late handler writes, -race reports a shared write
+	assert.Equal(t, "initial", resp)
+	<-finished
+	assert.Equal(t, "initial", resp)
+
+	require.NoError(t, eb.Reply("fast", func(event *Event) error {
+		*event.Response.(*string) = "pong"
+		return nil
+	}, WithMiddlewares(Timeout(time.Second))))
+	require.NoError(t, eb.Request("fast", &Event{Ctx: context.Background(), Response: &resp}))
+	assert.Equal(t, "pong", resp)
+}
+
+func TestEventBus_DropAndClose(t *testing.T) {
+	eb := newTestBus()
+
+	release := make(chan struct{})
+	var handled atomic.Int32
+	eb.Subscribe("created", func(event *Event) error {
+		<-release
+		handled.Add(1)
+		return nil
+	}, WithBuffer(1), WithBufferPolicy(BufferDrop))
+
+	// one event is handled, one is buffered, the third is dropped
+	require.NoError(t, eb.PublishAsync("created", &Event{Ctx: context.Background()}))
+	assert.Eventually(t, func() bool {
+		return eb.PublishAsync("created", &Event{Ctx: context.Background()}) == nil
+	}, time.Second, time.Millisecond)
+	assert.ErrorContains(t, eb.PublishAsync("created", &Event{Ctx: context.Background()}), ErrBufferFull.Error())
+
+	close(release)
+	require.NoError(t, eb.Close(context.Background()))
+	assert.Equal(t, int32(2), handled.Load())
+	assert.ErrorIs(t, eb.PublishAsync("created", &Event{Ctx: context.Background()}), ErrBusClosed)
+}
+
+func TestEventBus_PublishAsyncDetached(t *testing.T) {
+	eb := newTestBus()
+
+	release := make(chan struct{})
+	handled := make(chan error, 2)
+	eb.Subscribe("created", func(event *Event) error {
+		<-release
+		handled <- event.Ctx.Err()
+		return nil
+	})
+
+	// the second event waits in the buffer while its publisher ctx is canceled
+	ctx, cancel := context.WithCancel(context.Background())
+	require.NoError(t, eb.PublishAsync("created", &Event{Ctx: ctx}))
+	require.NoError(t, eb.PublishAsync("created", &Event{Ctx: ctx}))
+	cancel()
+
+	close(release)
+	assert.NoError(t, <-handled)
+	assert.NoError(t, <-handled)
+}
+
+func TestEventBus_BlockedPublish(t *testing.T) {
+	eb := newTestBus()
+
+	started, release := make(chan struct{}, 1), make(chan struct{})
+	eb.Subscribe("created", func(event *Event) error {
+		started <- struct{}{}
+		<-release
+		return nil
+	}, WithBuffer(1))
+
+	// the worker holds the first event and the second fills the buffer
+	require.NoError(t, eb.PublishAsync("created", &Event{Ctx: context.Background()}))
+	<-started
+	require.NoError(t, eb.PublishAsync("created", &Event{Ctx: context.Background()}))
+
+	published := make(chan error, 1)
+	go func() {
+		published <- eb.PublishAsync("created", &Event{Ctx: context.Background()})
+	}()
+	// let the publish block on the full buffer
+	time.Sleep(10 * time.Millisecond)
+
+	subscribed := make(chan struct{})
+	go func() {
+		eb.Subscribe("updated", func(event *Event) error { return nil })
+		close(subscribed)
+	}()
+	select {
+	case <-subscribed:
+	case <-time.After(time.Second):
+		t.Fatal("subscribe blocked by a publish waiting for buffer room")
+	}
+
+	closed := make(chan error, 1)
+	go func() {
+		closed <- eb.Close(context.Background())
+	}()
+	err := <-published
+	assert.True(t, errors.Is(err, ErrSubscriberStopped) || errors.Is(err, ErrBusClosed), err)
+
+	close(release)
+	require.NoError(t, <-closed)
+}
+
+func TestTopic(t *testing.T) {
+	eb := newTestBus()
+
+	type getUser struct{ ID string }
+	type user struct{ Name string }
+
+	topic := NewTopic[getUser, user](eb, "get_user")
+	require.NoError(t, topic.Reply(func(ctx context.Context, req getUser) (user, error) {
+		return user{Name: "user " + req.ID}, nil
+	}))
+
+	resp, err := topic.Request(context.Background(), getUser{ID: "1"})
+	require.NoError(t, err)
+	assert.Equal(t, "user 1", resp.Name)
+
+	// the string-typed API reaches the same responder
+	var legacy user
+	require.NoError(t, eb.Request("get_user", &Event{Ctx: context.Background(), Data: getUser{ID: "2"}, Response: &legacy}))
+	assert.Equal(t, "user 2", legacy.Name)
+
+	assert.ErrorIs(t, eb.Request("get_user", &Event{Ctx: context.Background(), Data: "2"}), ErrTypeMismatch)
+}
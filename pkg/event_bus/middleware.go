@@ -0,0 +1,141 @@
+package event_bus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	logger "go-source/pkg/log"
+	"go-source/pkg/metric"
+	"reflect"
+	"time"
+)
+
+const codeSuccess = "SUCCESS"
+
+var (
+	ErrHandlerPanic   = errors.New("event handler panic")
+	ErrHandlerTimeout = errors.New("event handler timeout")
+)
+
+// SubscriberInfo identifies the subscriber a middleware wraps the handler of.
+type SubscriberInfo struct {
+	EventType string
+	Name      string
+}
+
+// Middleware wraps the handler of a subscriber, it is applied once per subscriber.
+type Middleware func(info SubscriberInfo, next HandleEvent) HandleEvent
+
+func chain(info SubscriberInfo, fn HandleEvent, middlewares ...Middleware) HandleEvent {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		fn = middlewares[i](info, fn)
+	}
+	return fn
+}
+
+// Recover turns a handler panic into an ErrHandlerPanic error. Every subscriber has
+// it, outermost, so a panic never kills its workers.
+func Recover() Middleware {
+	return func(info SubscriberInfo, next HandleEvent) HandleEvent {
+		return func(event *Event) error {
+			return safeCall(info, next, event)
+		}
+	}
+}
+
+func safeCall(info SubscriberInfo, fn HandleEvent, event *Event) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			logger.GetLogger().AddTraceInfoContextRequest(event.Ctx).StackTrace().Error().
+				Msgf("event handler panic: type=%s subscriber=%s: %v", info.EventType, info.Name, r)
+			err = fmt.Errorf("%w: %v", ErrHandlerPanic, r)
+		}
+	}()
+
+	return fn(event)
+}
+
+// Timeout cancels the context of the handler after timeout and returns
+// ErrHandlerTimeout without waiting for it. Handlers must honour event.Ctx, one that
+// ignores it keeps running in its goroutine after the timeout. It fills a copy of
+// event.Response, copied back only when it returns in time, so a late handler never
+// writes the response the caller is reading.
+func Timeout(timeout time.Duration) Middleware {
+	return func(info SubscriberInfo, next HandleEvent) HandleEvent {
+		return func(event *Event) error {
+			ctx, cancel := context.WithTimeout(event.Ctx, timeout)
+			defer cancel()
+
+			// the event is shared with the other subscribers, only this copy gets the timeout
+			e := *event
+			e.Ctx = ctx
+
+			var scratch reflect.Value
+			if event.Response != nil {
+				response := reflect.ValueOf(event.Response)
+				scratch = reflect.New(response.Type().Elem())
+				scratch.Elem().Set(response.Elem())
+				e.Response = scratch.Interface()
+			}
+
+			done := make(chan error, 1)
+			go func() {
+				done <- safeCall(info, next, &e)
+			}()
+
+			select {
+			case err := <-done:
+				if scratch.IsValid() {
+					reflect.ValueOf(event.Response).Elem().Set(scratch.Elem())
+				}
+				return err
+			case <-ctx.Done():
+				return fmt.Errorf("%w: type=%s subscriber=%s: %v", ErrHandlerTimeout, info.EventType, info.Name, ctx.Err())
+			}
+		}
+	}
+}
+
+// Logging logs the failed events, and the handled ones at debug level, with trace info.
+func Logging() Middleware {
+	return func(info SubscriberInfo, next HandleEvent) HandleEvent {
+		return func(event *Event) error {
+			start := time.Now()
+			err := next(event)
+
+			log := logger.GetLogger().AddTraceInfoContextRequest(event.Ctx)
+			if err != nil {
+				log.Err(err).
+					Str("eventType", info.EventType).
+					Str("subscriber", info.Name).
+					Str("latency", time.Since(start).String()).
+					Msg("event handler failed")
+				return err
+			}
+
+			log.Debug().
+				Str("eventType", info.EventType).
+				Str("subscriber", info.Name).
+				Str("latency", time.Since(start).String()).
+				Msg("event handled")
+			return nil
+		}
+	}
+}
+
+// Metrics records the handler latency, labeled with the event type and result.
+func Metrics() Middleware {
+	return func(info SubscriberInfo, next HandleEvent) HandleEvent {
+		return func(event *Event) error {
+			start := time.Now()
+			err := next(event)
+
+			code := codeSuccess
+			if err != nil {
+				code = metric.DefaultErr.Error()
+			}
+			metric.NewEventBusHistogramDuration(info.EventType, code, time.Since(start))
+			return err
+		}
+	}
+}
@@ -0,0 +1,190 @@
+package event_bus
+
+import (
+	"context"
+	"errors"
+	logger "go-source/pkg/log"
+	"go-source/pkg/metric"
+	"sync"
+)
+
+const (
+	defaultBuffer      = 10
+	defaultConcurrency = 1
+)
+
+const (
+	// BufferBlock makes publishing wait for room in the buffer, or for the event ctx.
+	BufferBlock BufferPolicy = iota
+	// BufferDrop drops the event for the subscriber when its buffer is full.
+	BufferDrop
+)
+
+var (
+	ErrBufferFull        = errors.New("subscriber buffer is full")
+	ErrSubscriberStopped = errors.New("subscriber is stopped")
+)
+
+// BufferPolicy chooses what publishing does when a subscriber buffer is full.
+type BufferPolicy int
+
+type SubscribeOption func(s *subscriber)
+
+// WithBuffer sets how many events wait for the subscriber before the buffer policy applies.
+func WithBuffer(size int) SubscribeOption {
+	return func(s *subscriber) {
+		s.buffer = size
+	}
+}
+
+// WithConcurrency sets how many events the subscriber handles at the same time.
+func WithConcurrency(workers int) SubscribeOption {
+	return func(s *subscriber) {
+		s.concurrency = workers
+	}
+}
+
+func WithBufferPolicy(policy BufferPolicy) SubscribeOption {
+	return func(s *subscriber) {
+		s.policy = policy
+	}
+}
+
+// WithMiddlewares adds middlewares to the subscriber, after the ones of EventBus.Use.
+func WithMiddlewares(middlewares ...Middleware) SubscribeOption {
+	return func(s *subscriber) {
+		s.middlewares = append(s.middlewares, middlewares...)
+	}
+}
+
+type delivery struct {
+	event *Event
+	// done receives the handler error, nil for fire-and-forget deliveries
+	done chan error
+}
+
+type subscriber struct {
+	info        SubscriberInfo
+	fn          HandleEvent
+	buffer      int
+	concurrency int
+	policy      BufferPolicy
+	middlewares []Middleware
+	queue       chan *delivery
+	wg          sync.WaitGroup
+	// quit wakes up the publishers blocked on a full queue when the subscriber stops
+	quit    chan struct{}
+	stopped bool
+	mu      sync.RWMutex
+}
+
+func newSubscriber(info SubscriberInfo, fn HandleEvent, middlewares []Middleware, opts ...SubscribeOption) *subscriber {
+	s := &subscriber{
+		info:        info,
+		buffer:      defaultBuffer,
+		concurrency: defaultConcurrency,
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	if s.buffer < 0 {
+		s.buffer = 0
+	}
+	if s.concurrency <= 0 {
+		s.concurrency = defaultConcurrency
+	}
+
+	all := append([]Middleware{Recover()}, middlewares...)
+	s.fn = chain(info, fn, append(all, s.middlewares...)...)
+
+	s.queue = make(chan *delivery, s.buffer)
+	s.quit = make(chan struct{})
+	for i := 0; i < s.concurrency; i++ {
+		s.wg.Add(1)
+		go s.run()
+	}
+
+	return s
+}
+
+func (s *subscriber) run() {
+	defer s.wg.Done()
+
+	for d := range s.queue {
+		// fire-and-forget events are detached from the publisher ctx, only a caller
+		// waiting for the result may have given up on it
+		if d.done == nil {
+			_ = s.fn(d.event)
+			continue
+		}
+
+		var err error
+		select {
+		case <-d.event.Ctx.Done():
+			err = d.event.Ctx.Err()
+		default:
+			err = s.fn(d.event)
+		}
+		d.done <- err
+	}
+}
+
+// enqueue queues d according to the buffer policy, a full buffer blocks until ctx is
+// done. It fails once the subscriber is stopped.
+func (s *subscriber) enqueue(ctx context.Context, d *delivery) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.stopped {
+		return ErrSubscriberStopped
+	}
+
+	if s.policy == BufferDrop {
+		select {
+		case s.queue <- d:
+			return nil
+		default:
+			logger.GetLogger().AddTraceInfoContextRequest(ctx).Warn().
+				Msgf("event dropped, subscriber buffer is full: type=%s subscriber=%s", s.info.EventType, s.info.Name)
+			metric.NewEventBusDropCounter(s.info.EventType)
+			return ErrBufferFull
+		}
+	}
+
+	select {
+	case s.queue <- d:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-s.quit:
+		return ErrSubscriberStopped
+	}
+}
+
+// stop rejects the events being published, lets the workers handle the queued
+// ones, then returns.
+func (s *subscriber) stop() {
+	close(s.quit)
+
+	// wait for the enqueues in flight before closing the queue they send to
+	s.mu.Lock()
+	s.stopped = true
+	close(s.queue)
+	s.mu.Unlock()
+
+	s.wg.Wait()
+}
+
+func newDelivery(event *Event, wait bool) *delivery {
+	if event.Ctx == nil {
+		event.Ctx = context.Background()
+	}
+
+	d := &delivery{event: event}
+	if wait {
+		d.done = make(chan error, 1)
+	}
+	return d
+}
@@ -0,0 +1,93 @@
+package event_bus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+var ErrTypeMismatch = errors.New("event type mismatch")
+
+// Topic is a typed view of an event type of the bus: publishers and subscribers
+// share Req, and requesters and the responder share Resp. Events published with the
+// string-typed API are delivered too, when their Data is a Req.
+type Topic[Req, Resp any] struct {
+	bus  *EventBus
+	name string
+}
+
+func NewTopic[Req, Resp any](bus *EventBus, name string) *Topic[Req, Resp] {
+	return &Topic[Req, Resp]{bus: bus, name: name}
+}
+
+func (t *Topic[Req, Resp]) Name() string {
+	return t.name
+}
+
+// Publish sends evt to the subscribers and waits for them, as EventBus.Publish.
+func (t *Topic[Req, Resp]) Publish(ctx context.Context, evt Req) error {
+	return t.bus.Publish(t.name, &Event{Ctx: ctx, Data: evt})
+}
+
+// PublishAsync sends evt to the subscribers without waiting, as EventBus.PublishAsync.
+func (t *Topic[Req, Resp]) PublishAsync(ctx context.Context, evt Req) error {
+	return t.bus.PublishAsync(t.name, &Event{Ctx: ctx, Data: evt})
+}
+
+// Request sends req to the responder and returns its response.
+func (t *Topic[Req, Resp]) Request(ctx context.Context, req Req) (Resp, error) {
+	var resp Resp
+	err := t.bus.Request(t.name, &Event{Ctx: ctx, Data: req, Response: &resp})
+	return resp, err
+}
+
+// Subscribe adds the subscriber name, see EventBus.SubscribeWithName.
+func (t *Topic[Req, Resp]) Subscribe(name string, fn func(ctx context.Context, evt Req) error, opts ...SubscribeOption) error {
+	return t.bus.SubscribeWithName(t.name, name, func(event *Event) error {
+		evt, err := t.data(event)
+		if err != nil {
+			return err
+		}
+		return fn(event.Ctx, evt)
+	}, opts...)
+}
+
+func (t *Topic[Req, Resp]) Unsubscribe(name string) error {
+	return t.bus.Unsubscribe(t.name, name)
+}
+
+// Reply sets the responder of the requests, see EventBus.Reply.
+func (t *Topic[Req, Resp]) Reply(fn func(ctx context.Context, req Req) (Resp, error), opts ...SubscribeOption) error {
+	return t.bus.Reply(t.name, func(event *Event) error {
+		req, err := t.data(event)
+		if err != nil {
+			return err
+		}
+
+		resp, err := fn(event.Ctx, req)
+		if err != nil {
+			return err
+		}
+
+		switch ptr := event.Response.(type) {
+		case nil:
+		case *Resp:
+			*ptr = resp
+		default:
+			return fmt.Errorf("%w: %s response is %T, expected *%T", ErrTypeMismatch, t.name, event.Response, resp)
+		}
+		return nil
+	}, opts...)
+}
+
+func (t *Topic[Req, Resp]) StopReply() error {
+	return t.bus.StopReply(t.name)
+}
+
+func (t *Topic[Req, Resp]) data(event *Event) (Req, error) {
+	evt, ok := event.Data.(Req)
+	if !ok {
+		return evt, fmt.Errorf("%w: %s data is %T, expected %T", ErrTypeMismatch, t.name, event.Data, evt)
+	}
+	return evt, nil
+}
@@ -0,0 +1,178 @@
+package idempotency
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"go-source/pkg/utils"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	StatusProcessing = "PROCESSING"
+	StatusCompleted  = "COMPLETED"
+
+	keyPrefix = "idempotency:"
+)
+
+var (
+	ErrNilRedisClient = errors.New("idempotency: redis client is nil")
+	ErrRecordNotFound = errors.New("idempotency: record not found")
+	ErrLockLost       = errors.New("idempotency: lock expired or taken by another request")
+)
+
+// completeScript replaces the record only while it is still locked by the token in
+// ARGV[1], so a request whose lock expired cannot overwrite the one that took over.
+var completeScript = redis.NewScript(`
+local current = redis.call("GET", KEYS[1])
+if not current then
+	return 0
+end
+local ok, record = pcall(cjson.decode, current)
+if not ok or record.token ~= ARGV[1] then
+	return 0
+end
+if ARGV[2] == "" then
+	redis.call("DEL", KEYS[1])
+else
+	redis.call("SET", KEYS[1], ARGV[2], "PX", ARGV[3])
+end
+return 1
+`)
+
+// Record is what is kept for one idempotency key: the request fingerprint and,
+// once the first request finished, the response to replay.
+type Record struct {
+	Status      string              `json:"status"`
+	Fingerprint string              `json:"fingerprint"`
+	StatusCode  int                 `json:"statusCode,omitempty"`
+	Header      map[string][]string `json:"header,omitempty"`
+	Body        []byte              `json:"body,omitempty"`
+	CreatedAt   time.Time           `json:"createdAt"`
+	// Token identifies the request holding the processing lock, it is cleared on completion.
+	Token string `json:"token,omitempty"`
+}
+
+type StoreInterface interface {
+	// Acquire creates a processing record when the key is free and returns true.
+	// Otherwise it returns the existing record and false.
+	Acquire(ctx context.Context, key, fingerprint string, lockTTL time.Duration) (*Record, bool, error)
+	Get(ctx context.Context, key string) (*Record, error)
+	// Complete stores the completed record, it returns ErrLockLost when record.Token
+	// no longer holds the lock.
+	Complete(ctx context.Context, key string, record *Record, ttl time.Duration) error
+	// Release deletes the record when token still holds its lock.
+	Release(ctx context.Context, key, token string) error
+}
+
+type RedisStore struct {
+	client redis.UniversalClient
+}
+
+func NewRedisStore(client redis.UniversalClient) *RedisStore {
+	return &RedisStore{client: client}
+}
+
+func (s *RedisStore) Acquire(ctx context.Context, key, fingerprint string, lockTTL time.Duration) (*Record, bool, error) {
+	if s.client == nil {
+		return nil, false, ErrNilRedisClient
+	}
+
+	record := &Record{
+		Status:      StatusProcessing,
+		Fingerprint: fingerprint,
+		CreatedAt:   time.Now(),
+		Token:       utils.RandString(),
+	}
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		return nil, false, err
+	}
+
+	// the existing record may expire between SETNX and GET, so try twice
+	for attempt := 0; attempt < 2; attempt++ {
+		ok, err := s.client.SetNX(ctx, keyPrefix+key, data, lockTTL).Result()
+		if err != nil {
+			return nil, false, err
+		}
+
+		if ok {
+			return record, true, nil
+		}
+
+		existing, err := s.Get(ctx, key)
+		if errors.Is(err, ErrRecordNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, false, err
+		}
+
+		return existing, false, nil
+	}
+
+	return nil, false, ErrRecordNotFound
+}
+
+func (s *RedisStore) Get(ctx context.Context, key string) (*Record, error) {
+	if s.client == nil {
+		return nil, ErrNilRedisClient
+	}
+
+	data, err := s.client.Get(ctx, keyPrefix+key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrRecordNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var record Record
+	if err = json.Unmarshal(data, &record); err != nil {
+		return nil, err
+	}
+
+	return &record, nil
+}
+
+func (s *RedisStore) Complete(ctx context.Context, key string, record *Record, ttl time.Duration) error {
+	if s.client == nil {
+		return ErrNilRedisClient
+	}
+
+	token := record.Token
+	record.Status = StatusCompleted
+	record.Token = ""
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	return s.compareAndSet(ctx, key, token, string(data), ttl)
+}
+
+func (s *RedisStore) Release(ctx context.Context, key, token string) error {
+	if s.client == nil {
+		return ErrNilRedisClient
+	}
+	return s.compareAndSet(ctx, key, token, "", 0)
+}
+
+// compareAndSet sets data, or deletes the record when data is empty, if token holds the lock.
+func (s *RedisStore) compareAndSet(ctx context.Context, key, token, data string, ttl time.Duration) error {
+	if token == "" {
+		return ErrLockLost
+	}
+
+	res, err := completeScript.Run(ctx, s.client, []string{keyPrefix + key}, token, data, ttl.Milliseconds()).Int()
+	if err != nil {
+		return err
+	}
+	if res == 0 {
+		return ErrLockLost
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package idempotency
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedisStore_LockOwnership(t *testing.T) {
+	ctx := context.Background()
+	mr := miniredis.RunT(t)
+	store := NewRedisStore(redis.NewClient(&redis.Options{Addr: mr.Addr()}))
+
+	first, acquired, err := store.Acquire(ctx, "key", "fp", time.Second)
+	require.NoError(t, err)
+	require.True(t, acquired)
+
+	// the lock of the first request expires and a retry takes it over
+	mr.FastForward(2 * time.Second)
+	second, acquired, err := store.Acquire(ctx, "key", "fp", time.Second)
+	require.NoError(t, err)
+	require.True(t, acquired)
+
+	assert.ErrorIs(t, store.Release(ctx, "key", first.Token), ErrLockLost)
+	first.StatusCode = 500
+	assert.ErrorIs(t, store.Complete(ctx, "key", first, time.Minute), ErrLockLost)
+
+	token := second.Token
+	second.StatusCode = 201
+	require.NoError(t, store.Complete(ctx, "key", second, time.Minute))
+	record, err := store.Get(ctx, "key")
+	require.NoError(t, err)
+	assert.Equal(t, StatusCompleted, record.Status)
+	assert.Equal(t, 201, record.StatusCode)
+	assert.Empty(t, record.Token)
+
+	// a completed record is not released
+	assert.ErrorIs(t, store.Release(ctx, "key", token), ErrLockLost)
+}
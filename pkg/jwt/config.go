@@ -3,8 +3,15 @@ package jwt
 import "time"
 
 type JwtConfig struct {
-	Alg        string        `env:"ALG,required,notEmpty"` // support: RS256, RS384, RS512, ES256, ES384, ES512, PS256, PS384, PS512
-	PrivateKey string        `env:"PRIVATE_KEY,required,notEmpty"`
+	Alg        string        `env:"ALG,required,notEmpty"` // support: RS256, RS384, RS512, ES256, ES384, ES512, PS256, PS384, PS512, EdDSA, HS256, HS384, HS512
+	PrivateKey string        `env:"PRIVATE_KEY"`           // required by the RS*, ES*, PS* and EdDSA algs
+	Secret     string        `env:"SECRET"`                // required by the HS* algs, at least as long as the hash
 	Expire     time.Duration `env:"EXPIRE"`
 	RefExpire  time.Duration `env:"REF_EXPIRE"`
+
+	// Kid is the key id set in the token header, defaults to the thumbprint of the public key
+	Kid string `env:"KID"`
+	// PrevPublicKeys are the PEM public keys signing was rotated from, still published
+	// in JWKS and accepted until the tokens they signed have expired
+	PrevPublicKeys []string `env:"PREV_PUBLIC_KEYS" envSeparator:";"`
 }
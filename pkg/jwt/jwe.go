@@ -0,0 +1,439 @@
+package jwt
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"strings"
+)
+
+// JWE key management algs (RFC 7518 4), the content is always encrypted with A256GCM.
+const (
+	RSAOAEP      = "RSA-OAEP"
+	RSAOAEP256   = "RSA-OAEP-256"
+	ECDHES       = "ECDH-ES"
+	ECDHESA256KW = "ECDH-ES+A256KW"
+
+	A256GCM = "A256GCM"
+
+	ContentTypeJWT = "JWT"
+
+	cekSize = 32
+)
+
+var ErrDecryptFailed = errors.New("jwe decrypt failed")
+
+type jweHeader struct {
+	Alg string `json:"alg"`
+	Enc string `json:"enc"`
+	Cty string `json:"cty,omitempty"`
+	Kid string `json:"kid,omitempty"`
+	Epk *JWK   `json:"epk,omitempty"`
+}
+
+type jweOptions struct {
+	kid string
+	cty string
+}
+
+type JWEOption func(*jweOptions)
+
+// WithJWEKeyID sets the kid of the recipient key in the JWE header.
+func WithJWEKeyID(kid string) JWEOption {
+	return func(o *jweOptions) {
+		o.kid = kid
+	}
+}
+
+// WithJWEContentType sets the cty header, JWT for a nested signed token.
+func WithJWEContentType(cty string) JWEOption {
+	return func(o *jweOptions) {
+		o.cty = cty
+	}
+}
+
+// EncryptJWE encrypts plaintext for the owner of publicKey, a *rsa.PublicKey for the
+// RSA-OAEP algs or an *ecdsa.PublicKey for the ECDH-ES algs, in compact serialization.
+func EncryptJWE(plaintext []byte, alg string, publicKey interface{}, opts ...JWEOption) (string, error) {
+	o := &jweOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	header := jweHeader{Alg: alg, Enc: A256GCM, Cty: o.cty, Kid: o.kid}
+
+	var (
+		cek          []byte
+		encryptedKey []byte
+		err          error
+	)
+
+	switch alg {
+	case RSAOAEP, RSAOAEP256:
+		pub, ok := publicKey.(*rsa.PublicKey)
+		if !ok {
+			return "", fmt.Errorf("public key invalid")
+		}
+
+		if cek, err = randomBytes(cekSize); err != nil {
+			return "", err
+		}
+
+		if encryptedKey, err = rsa.EncryptOAEP(oaepHash(alg), rand.Reader, pub, cek, nil); err != nil {
+			return "", err
+		}
+
+	case ECDHES, ECDHESA256KW:
+		pub, ok := publicKey.(*ecdsa.PublicKey)
+		if !ok {
+			return "", fmt.Errorf("public key invalid")
+		}
+
+		recipient, err := pub.ECDH()
+		if err != nil {
+			return "", err
+		}
+
+		ephemeral, err := recipient.Curve().GenerateKey(rand.Reader)
+		if err != nil {
+			return "", err
+		}
+
+		epk, err := ephemeralJWK(ephemeral.PublicKey())
+		if err != nil {
+			return "", err
+		}
+		header.Epk = &epk
+
+		z, err := ephemeral.ECDH(recipient)
+		if err != nil {
+			return "", err
+		}
+
+		if alg == ECDHES {
+			// direct key agreement, the derived key is the CEK
+			cek = concatKDF(z, A256GCM, cekSize)
+			break
+		}
+
+		if cek, err = randomBytes(cekSize); err != nil {
+			return "", err
+		}
+
+		if encryptedKey, err = aesKeyWrap(concatKDF(z, alg, cekSize), cek); err != nil {
+			return "", err
+		}
+
+	default:
+		return "", fmt.Errorf("alg invalid: %s", alg)
+	}
+
+	byteHeader, err := json.Marshal(header)
+	if err != nil {
+		return "", err
+	}
+
+	protected := encodeBase64(byteHeader)
+
+	gcm, err := newGCM(cek)
+	if err != nil {
+		return "", err
+	}
+
+	iv, err := randomBytes(gcm.NonceSize())
+	if err != nil {
+		return "", err
+	}
+
+	// the additional authenticated data is the encoded protected header
+	sealed := gcm.Seal(nil, iv, plaintext, []byte(protected))
+	ciphertext, tag := sealed[:len(sealed)-gcm.Overhead()], sealed[len(sealed)-gcm.Overhead():]
+
+	return strings.Join([]string{
+		protected,
+		encodeBase64(encryptedKey),
+		encodeBase64(iv),
+		encodeBase64(ciphertext),
+		encodeBase64(tag),
+	}, "."), nil
+}
+
+// DecryptJWE decrypts a compact JWE with privateKey, a *rsa.PrivateKey or an *ecdsa.PrivateKey.
+func DecryptJWE(token string, privateKey interface{}) ([]byte, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 5 {
+		return nil, fmt.Errorf("%w: invalid number of segments", ErrTokenMalformed)
+	}
+
+	decoded := make([][]byte, len(parts))
+	for i, part := range parts {
+		b, err := decodeBase64(part)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrTokenMalformed, err)
+		}
+		decoded[i] = b
+	}
+
+	var header jweHeader
+	if err := json.Unmarshal(decoded[0], &header); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrTokenMalformed, err)
+	}
+
+	if header.Enc != A256GCM {
+		return nil, fmt.Errorf("enc invalid: %s", header.Enc)
+	}
+
+	var cek []byte
+
+	switch header.Alg {
+	case RSAOAEP, RSAOAEP256:
+		pk, ok := privateKey.(*rsa.PrivateKey)
+		if !ok {
+			return nil, fmt.Errorf("private key invalid")
+		}
+
+		var err error
+		if cek, err = rsa.DecryptOAEP(oaepHash(header.Alg), nil, pk, decoded[1], nil); err != nil {
+			return nil, ErrDecryptFailed
+		}
+
+	case ECDHES, ECDHESA256KW:
+		pk, ok := privateKey.(*ecdsa.PrivateKey)
+		if !ok {
+			return nil, fmt.Errorf("private key invalid")
+		}
+
+		if header.Epk == nil {
+			return nil, fmt.Errorf("%w: epk missing", ErrTokenMalformed)
+		}
+
+		z, err := ecdhSharedSecret(pk, *header.Epk)
+		if err != nil {
+			return nil, err
+		}
+
+		if header.Alg == ECDHES {
+			cek = concatKDF(z, A256GCM, cekSize)
+			break
+		}
+
+		if cek, err = aesKeyUnwrap(concatKDF(z, header.Alg, cekSize), decoded[1]); err != nil {
+			return nil, ErrDecryptFailed
+		}
+
+	default:
+		return nil, fmt.Errorf("alg invalid: %s", header.Alg)
+	}
+
+	gcm, err := newGCM(cek)
+	if err != nil {
+		return nil, ErrDecryptFailed
+	}
+
+	if len(decoded[2]) != gcm.NonceSize() {
+		return nil, fmt.Errorf("%w: iv size", ErrTokenMalformed)
+	}
+
+	plaintext, err := gcm.Open(nil, decoded[2], append(decoded[3], decoded[4]...), []byte(parts[0]))
+	if err != nil {
+		return nil, ErrDecryptFailed
+	}
+
+	return plaintext, nil
+}
+
+// SignAndEncrypt signs claims with j then encrypts the token for the owner of
+// recipientKey, as a nested JWT.
+func (j *JWT) SignAndEncrypt(claims interface{}, alg string, recipientKey interface{}, opts ...JWEOption) (string, error) {
+	token, err := j.SignToken(claims)
+	if err != nil {
+		return "", err
+	}
+
+	opts = append([]JWEOption{WithJWEContentType(ContentTypeJWT)}, opts...)
+
+	return EncryptJWE([]byte(token), alg, recipientKey, opts...)
+}
+
+// Decrypt decrypts a JWE encrypted for the key of j, the nested token still has to
+// be verified with the key of its signer.
+func (j *JWT) Decrypt(token string) ([]byte, error) {
+	return DecryptJWE(token, j.privateKey)
+}
+
+func ecdhSharedSecret(pk *ecdsa.PrivateKey, epk JWK) ([]byte, error) {
+	if epk.Kty != KtyEC {
+		return nil, fmt.Errorf("%w: epk kty %s", ErrTokenMalformed, epk.Kty)
+	}
+
+	ephemeral, err := epk.PublicKey()
+	if err != nil {
+		return nil, err
+	}
+
+	ephemeralPub, err := ephemeral.(*ecdsa.PublicKey).ECDH()
+	if err != nil {
+		return nil, err
+	}
+
+	private, err := pk.ECDH()
+	if err != nil {
+		return nil, err
+	}
+
+	// also rejects an ephemeral key on another curve
+	return private.ECDH(ephemeralPub)
+}
+
+func ephemeralJWK(pub *ecdh.PublicKey) (JWK, error) {
+	var crv string
+	switch pub.Curve() {
+	case ecdh.P256():
+		crv = "P-256"
+	case ecdh.P384():
+		crv = "P-384"
+	case ecdh.P521():
+		crv = "P-521"
+	default:
+		return JWK{}, fmt.Errorf("%w: ephemeral curve", ErrKeyUnsupported)
+	}
+
+	// uncompressed point: 0x04 || X || Y
+	point := pub.Bytes()[1:]
+	size := len(point) / 2
+
+	return JWK{Kty: KtyEC, Crv: crv, X: encodeBase64(point[:size]), Y: encodeBase64(point[size:])}, nil
+}
+
+// concatKDF derives a key of size bytes from the shared secret z, RFC 7518 4.6.2
+// with empty apu and apv.
+func concatKDF(z []byte, algID string, size int) []byte {
+	otherInfo := make([]byte, 0, 4+len(algID)+4+4+4)
+	otherInfo = binary.BigEndian.AppendUint32(otherInfo, uint32(len(algID)))
+	otherInfo = append(otherInfo, algID...)
+	otherInfo = binary.BigEndian.AppendUint32(otherInfo, 0) // apu
+	otherInfo = binary.BigEndian.AppendUint32(otherInfo, 0) // apv
+	otherInfo = binary.BigEndian.AppendUint32(otherInfo, uint32(size*8))
+
+	var key []byte
+	for counter := uint32(1); len(key) < size; counter++ {
+		h := sha256.New()
+		_ = binary.Write(h, binary.BigEndian, counter)
+		h.Write(z)
+		h.Write(otherInfo)
+		key = h.Sum(key)
+	}
+
+	return key[:size]
+}
+
+var keyWrapIV = []byte{0xA6, 0xA6, 0xA6, 0xA6, 0xA6, 0xA6, 0xA6, 0xA6}
+
+// aesKeyWrap wraps key with kek, RFC 3394.
+func aesKeyWrap(kek, key []byte) ([]byte, error) {
+	if len(key)%8 != 0 {
+		return nil, fmt.Errorf("key wrap: key size must be a multiple of 8")
+	}
+
+	block, err := aes.NewCipher(kek)
+	if err != nil {
+		return nil, err
+	}
+
+	n := len(key) / 8
+	r := make([]byte, len(key))
+	copy(r, key)
+
+	a := make([]byte, 8)
+	copy(a, keyWrapIV)
+
+	b := make([]byte, 16)
+	for j := 0; j < 6; j++ {
+		for i := 0; i < n; i++ {
+			copy(b, a)
+			copy(b[8:], r[i*8:(i+1)*8])
+			block.Encrypt(b, b)
+
+			t := uint64(n*j + i + 1)
+			binary.BigEndian.PutUint64(a, binary.BigEndian.Uint64(b[:8])^t)
+			copy(r[i*8:], b[8:])
+		}
+	}
+
+	return append(a, r...), nil
+}
+
+// aesKeyUnwrap unwraps the key wrapped by aesKeyWrap.
+func aesKeyUnwrap(kek, wrapped []byte) ([]byte, error) {
+	if len(wrapped)%8 != 0 || len(wrapped) < 24 {
+		return nil, fmt.Errorf("key unwrap: invalid wrapped key size")
+	}
+
+	block, err := aes.NewCipher(kek)
+	if err != nil {
+		return nil, err
+	}
+
+	n := len(wrapped)/8 - 1
+	r := make([]byte, n*8)
+	copy(r, wrapped[8:])
+
+	a := make([]byte, 8)
+	copy(a, wrapped[:8])
+
+	b := make([]byte, 16)
+	for j := 5; j >= 0; j-- {
+		for i := n - 1; i >= 0; i-- {
+			t := uint64(n*j + i + 1)
+			binary.BigEndian.PutUint64(b, binary.BigEndian.Uint64(a)^t)
+			copy(b[8:], r[i*8:(i+1)*8])
+			block.Decrypt(b, b)
+
+			copy(a, b[:8])
+			copy(r[i*8:], b[8:])
+		}
+	}
+
+	if subtle.ConstantTimeCompare(a, keyWrapIV) != 1 {
+		return nil, fmt.Errorf("key unwrap: integrity check failed")
+	}
+
+	return r, nil
+}
+
+func oaepHash(alg string) hash.Hash {
+	if alg == RSAOAEP {
+		return sha1.New()
+	}
+	return sha256.New()
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+func randomBytes(n int) ([]byte, error) {
+	b := make([]byte, n)
+	if _, err := io.ReadFull(rand.Reader, b); err != nil {
+		return nil, err
+	}
+
+	return b, nil
+}
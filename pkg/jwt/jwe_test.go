@@ -0,0 +1,91 @@
+package jwt
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/hex"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAESKeyWrap_RFC3394(t *testing.T) {
+	kek, _ := hex.DecodeString("000102030405060708090A0B0C0D0E0F101112131415161718191A1B1C1D1E1F")
+	key, _ := hex.DecodeString("00112233445566778899AABBCCDDEEFF000102030405060708090A0B0C0D0E0F")
+	want, _ := hex.DecodeString("28C9F404C4B810F4CBCCB35CFB87F8263F5786E2D80ED326CBC7F0E71A99F43BFB988B9B7A02DD21")
+
+	wrapped, err := aesKeyWrap(kek, key)
+	require.NoError(t, err)
+	assert.Equal(t, want, wrapped)
+
+	unwrapped, err := aesKeyUnwrap(kek, wrapped)
+	require.NoError(t, err)
+	assert.Equal(t, key, unwrapped)
+
+	wrapped[0] ^= 1
+	_, err = aesKeyUnwrap(kek, wrapped)
+	assert.Error(t, err)
+}
+
+func TestJWE_RoundTrip(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	require.NoError(t, err)
+
+	tests := []struct {
+		alg        string
+		publicKey  interface{}
+		privateKey interface{}
+	}{
+		{alg: RSAOAEP, publicKey: &rsaKey.PublicKey, privateKey: rsaKey},
+		{alg: RSAOAEP256, publicKey: &rsaKey.PublicKey, privateKey: rsaKey},
+		{alg: ECDHES, publicKey: &ecKey.PublicKey, privateKey: ecKey},
+		{alg: ECDHESA256KW, publicKey: &ecKey.PublicKey, privateKey: ecKey},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.alg, func(t *testing.T) {
+			token, err := EncryptJWE([]byte("payload"), tt.alg, tt.publicKey, WithJWEKeyID("partner"))
+			require.NoError(t, err)
+			assert.Len(t, strings.Split(token, "."), 5)
+
+			plaintext, err := DecryptJWE(token, tt.privateKey)
+			require.NoError(t, err)
+			assert.Equal(t, "payload", string(plaintext))
+
+			// tampering with the protected header breaks the authentication tag
+			parts := strings.Split(token, ".")
+			parts[0] = encodeBase64([]byte(`{"alg":"` + tt.alg + `","enc":"A256GCM","kid":"other"}`))
+			_, err = DecryptJWE(strings.Join(parts, "."), tt.privateKey)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestSignAndEncrypt_HS256(t *testing.T) {
+	j, err := NewJWT(JwtConfig{Alg: HS256, Secret: strings.Repeat("s", 32), Expire: time.Minute})
+	require.NoError(t, err)
+	assert.Empty(t, j.JWKS().Keys)
+
+	_, err = NewJWT(JwtConfig{Alg: HS512, Secret: strings.Repeat("s", 32)})
+	assert.Error(t, err)
+
+	partner, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	token, err := j.SignAndEncrypt(map[string]interface{}{"sub": "service"}, ECDHESA256KW, &partner.PublicKey)
+	require.NoError(t, err)
+
+	nested, err := DecryptJWE(token, partner)
+	require.NoError(t, err)
+
+	var claims map[string]interface{}
+	require.NoError(t, VerifyAndParseClaims(string(nested), HS256, []byte(strings.Repeat("s", 32)), &claims))
+	assert.Equal(t, "service", claims["sub"])
+}
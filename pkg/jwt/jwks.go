@@ -0,0 +1,265 @@
+package jwt
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	KtyRSA = "RSA"
+	KtyEC  = "EC"
+	KtyOKP = "OKP"
+
+	UseSig = "sig"
+)
+
+var (
+	ErrKeyNotFound    = errors.New("jwk not found")
+	ErrKeyUnsupported = errors.New("jwk unsupported")
+)
+
+// JWK is a public JSON Web Key (RFC 7517), private members are never set.
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid,omitempty"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg,omitempty"`
+
+	// RSA
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+
+	// EC, OKP
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// NewJWK builds the JWK of a public key, kid defaults to the key thumbprint.
+func NewJWK(kid, alg string, publicKey interface{}) (JWK, error) {
+	var key JWK
+
+	switch pub := publicKey.(type) {
+	case *rsa.PublicKey:
+		key = JWK{
+			Kty: KtyRSA,
+			N:   encodeBase64(pub.N.Bytes()),
+			E:   encodeBase64(big.NewInt(int64(pub.E)).Bytes()),
+		}
+
+	case *ecdsa.PublicKey:
+		crv, size, err := curveName(pub.Curve)
+		if err != nil {
+			return JWK{}, err
+		}
+
+		key = JWK{
+			Kty: KtyEC,
+			Crv: crv,
+			X:   encodeBase64(pub.X.FillBytes(make([]byte, size))),
+			Y:   encodeBase64(pub.Y.FillBytes(make([]byte, size))),
+		}
+
+	case ed25519.PublicKey:
+		key = JWK{
+			Kty: KtyOKP,
+			Crv: "Ed25519",
+			X:   encodeBase64(pub),
+		}
+
+	default:
+		return JWK{}, fmt.Errorf("%w: key type %T", ErrKeyUnsupported, publicKey)
+	}
+
+	if kid == "" {
+		kid = key.Thumbprint()
+	}
+
+	key.Kid = kid
+	key.Use = UseSig
+	key.Alg = alg
+
+	return key, nil
+}
+
+// PublicKey returns the crypto public key of the JWK.
+func (k JWK) PublicKey() (interface{}, error) {
+	switch k.Kty {
+	case KtyRSA:
+		n, err := decodeBase64(k.N)
+		if err != nil {
+			return nil, err
+		}
+
+		e, err := decodeBase64(k.E)
+		if err != nil {
+			return nil, err
+		}
+
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+
+	case KtyEC:
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("%w: crv %s", ErrKeyUnsupported, k.Crv)
+		}
+
+		x, err := decodeBase64(k.X)
+		if err != nil {
+			return nil, err
+		}
+
+		y, err := decodeBase64(k.Y)
+		if err != nil {
+			return nil, err
+		}
+
+		pub := &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !curve.IsOnCurve(pub.X, pub.Y) {
+			return nil, fmt.Errorf("%w: point not on curve", ErrKeyUnsupported)
+		}
+
+		return pub, nil
+
+	case KtyOKP:
+		if k.Crv != "Ed25519" {
+			return nil, fmt.Errorf("%w: crv %s", ErrKeyUnsupported, k.Crv)
+		}
+
+		x, err := decodeBase64(k.X)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("%w: invalid Ed25519 key size", ErrKeyUnsupported)
+		}
+
+		return ed25519.PublicKey(x), nil
+
+	default:
+		return nil, fmt.Errorf("%w: kty %s", ErrKeyUnsupported, k.Kty)
+	}
+}
+
+// Thumbprint returns the RFC 7638 SHA-256 thumbprint of the key.
+func (k JWK) Thumbprint() string {
+	// required members only, in lexicographic order
+	var members string
+	switch k.Kty {
+	case KtyRSA:
+		members = fmt.Sprintf(`{"e":%q,"kty":%q,"n":%q}`, k.E, k.Kty, k.N)
+	case KtyEC:
+		members = fmt.Sprintf(`{"crv":%q,"kty":%q,"x":%q,"y":%q}`, k.Crv, k.Kty, k.X, k.Y)
+	default:
+		members = fmt.Sprintf(`{"crv":%q,"kty":%q,"x":%q}`, k.Crv, k.Kty, k.X)
+	}
+
+	sum := sha256.Sum256([]byte(members))
+
+	return encodeBase64(sum[:])
+}
+
+// Key returns the key with the given kid.
+func (s JWKS) Key(kid string) (JWK, bool) {
+	for _, key := range s.Keys {
+		if key.Kid == kid {
+			return key, true
+		}
+	}
+
+	return JWK{}, false
+}
+
+// JWKS returns the public keys of the current and previous signing keys, to be
+// served to the verifiers.
+func (j *JWT) JWKS() JWKS {
+	return JWKS{Keys: append([]JWK(nil), j.jwks...)}
+}
+
+// JWKSHandler serves JWKS, usually on /.well-known/jwks.json.
+func (j *JWT) JWKSHandler() echo.HandlerFunc {
+	body, _ := json.Marshal(j.JWKS())
+
+	return func(c echo.Context) error {
+		c.Response().Header().Set(echo.HeaderCacheControl, "public, max-age=300")
+		return c.JSONBlob(http.StatusOK, body)
+	}
+}
+
+// KeyID returns the kid set in the header of the tokens signed by j.
+func (j *JWT) KeyID() string {
+	return j.kid
+}
+
+// algMatchesKey reports whether the signing method alg can be used with publicKey.
+func algMatchesKey(alg string, publicKey interface{}) bool {
+	switch publicKey.(type) {
+	case *rsa.PublicKey:
+		switch alg {
+		case RS256, RS384, RS512, PS256, PS384, PS512:
+			return true
+		}
+
+	case *ecdsa.PublicKey:
+		switch alg {
+		case ES256, ES384, ES512:
+			return true
+		}
+
+	case ed25519.PublicKey:
+		return alg == EdDSA
+
+	case []byte:
+		return isHMAC(alg)
+	}
+
+	return false
+}
+
+func isHMAC(alg string) bool {
+	return alg == HS256 || alg == HS384 || alg == HS512
+}
+
+func curveName(curve elliptic.Curve) (string, int, error) {
+	switch curve {
+	case elliptic.P256():
+		return "P-256", 32, nil
+	case elliptic.P384():
+		return "P-384", 48, nil
+	case elliptic.P521():
+		return "P-521", 66, nil
+	default:
+		return "", 0, fmt.Errorf("%w: curve %s", ErrKeyUnsupported, curve.Params().Name)
+	}
+}
+
+func encodeBase64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeBase64(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(s)
+}
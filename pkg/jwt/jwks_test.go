@@ -0,0 +1,97 @@
+package jwt
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestJWT(t *testing.T, prevPublicKeys ...string) (*JWT, string) {
+	t.Helper()
+
+	pk, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	der, err := x509.MarshalECPrivateKey(pk)
+	require.NoError(t, err)
+
+	pubDer, err := x509.MarshalPKIXPublicKey(&pk.PublicKey)
+	require.NoError(t, err)
+
+	j, err := NewJWT(JwtConfig{
+		Alg:            ES256,
+		PrivateKey:     string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})),
+		Expire:         time.Minute,
+		PrevPublicKeys: prevPublicKeys,
+	})
+	require.NoError(t, err)
+
+	return j, string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubDer}))
+}
+
+func writeJWKS(t *testing.T, path string, jwks JWKS) {
+	t.Helper()
+
+	data, err := json.Marshal(jwks)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path, data, 0o600))
+}
+
+func TestJWK_RoundTrip(t *testing.T) {
+	j, _ := newTestJWT(t)
+
+	key, ok := j.JWKS().Key(j.KeyID())
+	require.True(t, ok)
+	assert.Equal(t, key.Thumbprint(), key.Kid)
+
+	pubKey, err := key.PublicKey()
+	require.NoError(t, err)
+	assert.True(t, pubKey.(*ecdsa.PublicKey).Equal(j.publicKey))
+}
+
+func TestVerifier_Rotation(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "jwks.json")
+
+	oldJWT, oldPub := newTestJWT(t)
+	writeJWKS(t, path, oldJWT.JWKS())
+
+	v := NewVerifier(NewFileSource(path), WithRefreshPeriod(0))
+
+	oldToken, err := oldJWT.SignToken(map[string]interface{}{"sub": "old"})
+	require.NoError(t, err)
+
+	var claims map[string]interface{}
+	require.NoError(t, v.VerifyAndParseClaims(ctx, oldToken, &claims))
+	assert.Equal(t, "old", claims["sub"])
+
+	// rotate: the new key is unknown to the cache and fetched on first use
+	newJWT, _ := newTestJWT(t, oldPub)
+	writeJWKS(t, path, newJWT.JWKS())
+	assert.Len(t, newJWT.JWKS().Keys, 2)
+
+	newToken, err := newJWT.SignToken(map[string]interface{}{"sub": "new"})
+	require.NoError(t, err)
+	require.NoError(t, v.VerifyAndParseClaims(ctx, newToken, &claims))
+	assert.Equal(t, "new", claims["sub"])
+
+	// tokens of the previous key are still accepted, by the verifier and the issuer
+	require.NoError(t, v.VerifyAndParseClaims(ctx, oldToken, &claims))
+	require.NoError(t, newJWT.VerifyAndParseToken(oldToken, &claims))
+
+	other, _ := newTestJWT(t)
+	otherToken, err := other.SignToken(map[string]interface{}{"sub": "other"})
+	require.NoError(t, err)
+	assert.ErrorIs(t, v.VerifyAndParseClaims(ctx, otherToken, &claims), ErrKeyNotFound)
+}
@@ -1,9 +1,7 @@
 package jwt
 
 import (
-	"crypto/ecdsa"
 	"crypto/ed25519"
-	"crypto/rsa"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
@@ -24,14 +22,22 @@ const (
 	PS384 = "PS384"
 	PS512 = "PS512"
 	EdDSA = "EdDSA"
+	HS256 = "HS256"
+	HS384 = "HS384"
+	HS512 = "HS512"
 )
 
 type JWT struct {
 	alg        string
+	kid        string
 	privateKey interface{}
 	publicKey  interface{}
 	expire     time.Duration
 	refExpire  time.Duration
+
+	// jwks holds the current key first, then the previous ones
+	jwks     []JWK
+	prevKeys map[string]interface{}
 }
 
 func NewJWT(cfg JwtConfig) (*JWT, error) {
@@ -93,13 +99,86 @@ func NewJWT(cfg JwtConfig) (*JWT, error) {
 		_jwt.publicKey = pubEd25519
 		break
 
+	case HS256, HS384, HS512:
+		// the secret must be at least as long as the hash output, RFC 7518 3.2
+		if len(cfg.Secret) < jwt.GetSigningMethod(cfg.Alg).(*jwt.SigningMethodHMAC).Hash.Size() {
+			return nil, fmt.Errorf("secret too short for %s", cfg.Alg)
+		}
+
+		_jwt.privateKey = []byte(cfg.Secret)
+		_jwt.publicKey = []byte(cfg.Secret)
+		break
+
 	default:
 		return nil, fmt.Errorf("alg invalid: %s", cfg.Alg)
 	}
 
+	if err := _jwt.initJWKS(cfg); err != nil {
+		return nil, err
+	}
+
 	return _jwt, nil
 }
 
+func (j *JWT) initJWKS(cfg JwtConfig) error {
+	j.prevKeys = make(map[string]interface{}, len(cfg.PrevPublicKeys))
+
+	// a shared secret is never published
+	if isHMAC(j.alg) {
+		j.kid = cfg.Kid
+		return nil
+	}
+
+	current, err := NewJWK(cfg.Kid, j.alg, j.publicKey)
+	if err != nil {
+		return err
+	}
+
+	j.kid = current.Kid
+	j.jwks = []JWK{current}
+
+	for _, pem := range cfg.PrevPublicKeys {
+		pubKey, err := ParsePublicKeyFromPEM(pem)
+		if err != nil {
+			return fmt.Errorf("parse previous public key: %w", err)
+		}
+
+		// the previous key may use another alg family, it is then published without alg
+		alg := j.alg
+		if !algMatchesKey(alg, pubKey) {
+			alg = ""
+		}
+
+		key, err := NewJWK("", alg, pubKey)
+		if err != nil {
+			return err
+		}
+
+		j.jwks = append(j.jwks, key)
+		j.prevKeys[key.Kid] = pubKey
+	}
+
+	return nil
+}
+
+// ParsePublicKeyFromPEM parses a RSA, EC or Ed25519 public key.
+func ParsePublicKeyFromPEM(pem string) (interface{}, error) {
+	if pub, err := jwt.ParseRSAPublicKeyFromPEM([]byte(pem)); err == nil {
+		return pub, nil
+	}
+
+	if pub, err := jwt.ParseECPublicKeyFromPEM([]byte(pem)); err == nil {
+		return pub, nil
+	}
+
+	pub, err := jwt.ParseEdPublicKeyFromPEM([]byte(pem))
+	if err != nil {
+		return nil, fmt.Errorf("public key invalid")
+	}
+
+	return pub, nil
+}
+
 func (j *JWT) SignToken(claims interface{}) (string, error) {
 	byteClaim, err := json.Marshal(claims)
 	if err != nil {
@@ -113,43 +192,36 @@ func (j *JWT) SignToken(claims interface{}) (string, error) {
 	}
 
 	token := jwt.NewWithClaims(jwt.GetSigningMethod(j.alg), mapClaims)
+	if j.kid != "" {
+		token.Header["kid"] = j.kid
+	}
 
 	return token.SignedString(j.privateKey)
 }
 
-func (j *JWT) VerifyAndParseToken(tokenStr string, claims interface{}) error {
-	// Parse jwt and check sign
-	token, err := jwt.Parse(tokenStr, func(token *jwt.Token) (interface{}, error) {
+// VerifyAndParseToken verifies a token signed by j, or by a previous key, and validates
+// its claims with opts.
+func (j *JWT) VerifyAndParseToken(tokenStr string, claims interface{}, opts ...ValidationOption) error {
+	return parseAndValidate(tokenStr, func(token *jwt.Token) (interface{}, error) {
 		// check signing method
 		alg := token.Method.Alg()
-		if alg != j.alg {
-			return nil, fmt.Errorf("signing method invalid: %v", alg)
-		}
-
-		return j.publicKey, nil
-	})
 
-	if err != nil {
-		return err
-	}
-
-	// check token valid
-	if !token.Valid {
-		return fmt.Errorf("token invalid")
-	}
+		// tokens signed before a rotation are verified with the previous key
+		kid, _ := token.Header["kid"].(string)
+		if pubKey, ok := j.prevKeys[kid]; ok {
+			if !algMatchesKey(alg, pubKey) {
+				return nil, fmt.Errorf("%w: %v", ErrTokenAlgNotAllowed, alg)
+			}
 
-	// parse claims
-	byteClaim, err := json.Marshal(token.Claims)
-	if err != nil {
-		return err
-	}
+			return pubKey, nil
+		}
 
-	err = json.Unmarshal(byteClaim, claims)
-	if err != nil {
-		return err
-	}
+		if alg != j.alg {
+			return nil, fmt.Errorf("%w: %v", ErrTokenAlgNotAllowed, alg)
+		}
 
-	return nil
+		return j.publicKey, nil
+	}, claims, opts)
 }
 
 func (j *JWT) GetExpire() time.Duration {
@@ -204,99 +276,31 @@ func ParseHeader(tokenStr string, header interface{}) error {
 	return nil
 }
 
-func Verify(tokenStr, alg string, publicKey interface{}) error {
-	// Parse jwt and check sign
-	token, err := jwt.Parse(tokenStr, func(token *jwt.Token) (interface{}, error) {
-		// check signing method
-		if token.Method.Alg() != alg {
-			return nil, fmt.Errorf("signing method invalid: %v", token.Method.Alg())
-		}
-
-		// parse public key
-		switch alg {
-		case RS256, RS384, RS512, PS256, PS384, PS512:
-			pubKey, ok := publicKey.(*rsa.PublicKey)
-			if !ok {
-				return nil, fmt.Errorf("public key invalid")
-			}
-
-			return pubKey, nil
-
-		case ES256, ES384, ES512:
-			pubKey, ok := publicKey.(*ecdsa.PublicKey)
-			if !ok {
-				return nil, fmt.Errorf("public key invalid")
-			}
-
-			return pubKey, nil
-
-		default:
-			return nil, fmt.Errorf("alg invalid: %s", alg)
-		}
-	})
-
-	if err != nil {
-		return err
-	}
-
-	// check token valid
-	if !token.Valid {
-		return fmt.Errorf("token invalid")
-	}
+func Verify(tokenStr, alg string, publicKey interface{}, opts ...ValidationOption) error {
+	return parseAndValidate(tokenStr, publicKeyFunc(alg, publicKey), nil, opts)
+}
 
-	return nil
+func VerifyAndParseClaims(tokenStr, alg string, publicKey, claims interface{}, opts ...ValidationOption) error {
+	return parseAndValidate(tokenStr, publicKeyFunc(alg, publicKey), claims, opts)
 }
 
-func VerifyAndParseClaims(tokenStr, alg string, publicKey, claims interface{}) error {
-	// Parse jwt and check sign
-	token, err := jwt.Parse(tokenStr, func(token *jwt.Token) (interface{}, error) {
+func publicKeyFunc(alg string, publicKey interface{}) jwt.Keyfunc {
+	return func(token *jwt.Token) (interface{}, error) {
 		// check signing method
 		if token.Method.Alg() != alg {
-			return nil, fmt.Errorf("signing method invalid: %v", token.Method.Alg())
+			return nil, fmt.Errorf("%w: %v", ErrTokenAlgNotAllowed, token.Method.Alg())
 		}
 
-		// parse public key
 		switch alg {
-		case RS256, RS384, RS512, PS256, PS384, PS512:
-			pubKey, ok := publicKey.(*rsa.PublicKey)
-			if !ok {
-				return nil, fmt.Errorf("public key invalid")
-			}
-
-			return pubKey, nil
-
-		case ES256, ES384, ES512:
-			pubKey, ok := publicKey.(*ecdsa.PublicKey)
-			if !ok {
+		case RS256, RS384, RS512, PS256, PS384, PS512, ES256, ES384, ES512, EdDSA, HS256, HS384, HS512:
+			if !algMatchesKey(alg, publicKey) {
 				return nil, fmt.Errorf("public key invalid")
 			}
 
-			return pubKey, nil
+			return publicKey, nil
 
 		default:
 			return nil, fmt.Errorf("alg invalid: %s", alg)
 		}
-	})
-
-	if err != nil {
-		return err
 	}
-
-	// check token valid
-	if !token.Valid {
-		return fmt.Errorf("token invalid")
-	}
-
-	// parse claims
-	byteClaim, err := json.Marshal(token.Claims)
-	if err != nil {
-		return err
-	}
-
-	err = json.Unmarshal(byteClaim, claims)
-	if err != nil {
-		return err
-	}
-
-	return nil
 }
@@ -0,0 +1,229 @@
+package jwt
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-source/pkg/utils"
+	"maps"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	ClaimJti       = "jti"
+	ClaimIat       = "iat"
+	ClaimTokenType = "typ"
+	ClaimSessionID = "sid"
+
+	TokenTypeAccess  = "access"
+	TokenTypeRefresh = "refresh"
+
+	defaultAccessExpire  = 15 * time.Minute
+	defaultRefreshExpire = 7 * 24 * time.Hour
+)
+
+var (
+	ErrTokenRevoked     = errors.New("token revoked")
+	ErrTokenTypeInvalid = errors.New("token type invalid")
+)
+
+type TokenPair struct {
+	AccessToken      string `json:"accessToken"`
+	RefreshToken     string `json:"refreshToken"`
+	TokenType        string `json:"tokenType"`
+	ExpiresIn        int64  `json:"expiresIn"`
+	RefreshExpiresIn int64  `json:"refreshExpiresIn"`
+}
+
+// TokenService issues access/refresh token pairs. Each login starts a session, the
+// refresh token family: refreshing rotates the refresh token, and presenting a
+// rotated one again revokes the whole session, as it was likely stolen.
+type TokenService struct {
+	jwt           *JWT
+	store         SessionStore
+	accessExpire  time.Duration
+	refreshExpire time.Duration
+}
+
+func NewTokenService(j *JWT, store SessionStore) *TokenService {
+	s := &TokenService{
+		jwt:           j,
+		store:         store,
+		accessExpire:  j.GetExpire(),
+		refreshExpire: j.GetRefExpire(),
+	}
+
+	if s.accessExpire <= 0 {
+		s.accessExpire = defaultAccessExpire
+	}
+	if s.refreshExpire <= 0 {
+		s.refreshExpire = defaultRefreshExpire
+	}
+
+	return s
+}
+
+// IssuePair starts a new session of sub, claims are added to the access token.
+func (s *TokenService) IssuePair(ctx context.Context, sub string, claims map[string]interface{}) (*TokenPair, error) {
+	sid := utils.RandString()
+	refreshJti := utils.RandString()
+
+	if err := s.store.CreateSession(ctx, sid, sub, refreshJti, s.refreshExpire); err != nil {
+		return nil, err
+	}
+
+	return s.signPair(sub, sid, refreshJti, claims)
+}
+
+// Refresh rotates the refresh token and issues a new pair, claims are added to the
+// access token.
+func (s *TokenService) Refresh(ctx context.Context, refreshToken string, claims map[string]interface{}) (*TokenPair, error) {
+	var refresh map[string]interface{}
+	if err := s.jwt.VerifyAndParseToken(refreshToken, &refresh, WithRequiredClaims(utils.JwtSub, ClaimJti, ClaimSessionID)); err != nil {
+		return nil, err
+	}
+
+	if refresh[ClaimTokenType] != TokenTypeRefresh {
+		return nil, fmt.Errorf("%w: expected %s", ErrTokenTypeInvalid, TokenTypeRefresh)
+	}
+
+	sub, _ := refresh[utils.JwtSub].(string)
+	sid, _ := refresh[ClaimSessionID].(string)
+	jti, _ := refresh[ClaimJti].(string)
+
+	nextJti := utils.RandString()
+	if err := s.store.RotateSession(ctx, sid, jti, nextJti, s.refreshExpire); err != nil {
+		if errors.Is(err, ErrSessionRevoked) || errors.Is(err, ErrRefreshTokenReused) {
+			return nil, fmt.Errorf("%w: %w", ErrTokenRevoked, err)
+		}
+		return nil, err
+	}
+
+	return s.signPair(sub, sid, nextJti, claims)
+}
+
+// VerifyAndParseClaims verifies an access token issued by s and not revoked, so s
+// can be used as the token verifier of the Authorization middleware.
+func (s *TokenService) VerifyAndParseClaims(ctx context.Context, tokenStr string, claims interface{}, opts ...ValidationOption) error {
+	access, err := s.verifyAccess(ctx, tokenStr, opts)
+	if err != nil {
+		return err
+	}
+
+	// parse claims
+	byteClaim, err := json.Marshal(access)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(byteClaim, claims)
+}
+
+// Revoke revokes an access token before it expires, its session is kept.
+func (s *TokenService) Revoke(ctx context.Context, accessToken string) error {
+	access, err := s.verifyAccess(ctx, accessToken, nil)
+	if err != nil {
+		return err
+	}
+
+	return s.deny(ctx, access)
+}
+
+// Logout revokes the access token and its session.
+func (s *TokenService) Logout(ctx context.Context, accessToken string) error {
+	access, err := s.verifyAccess(ctx, accessToken, nil)
+	if err != nil {
+		return err
+	}
+
+	if err := s.deny(ctx, access); err != nil {
+		return err
+	}
+
+	if sid, _ := access[ClaimSessionID].(string); sid != "" {
+		return s.store.RevokeSession(ctx, sid)
+	}
+
+	return nil
+}
+
+// LogoutAll revokes all the sessions of sub, their access tokens are rejected too.
+func (s *TokenService) LogoutAll(ctx context.Context, sub string) error {
+	return s.store.RevokeSubject(ctx, sub)
+}
+
+func (s *TokenService) verifyAccess(ctx context.Context, tokenStr string, opts []ValidationOption) (map[string]interface{}, error) {
+	var access map[string]interface{}
+	opts = append([]ValidationOption{WithRequiredClaims(ClaimJti)}, opts...)
+	if err := s.jwt.VerifyAndParseToken(tokenStr, &access, opts...); err != nil {
+		return nil, err
+	}
+
+	if access[ClaimTokenType] != TokenTypeAccess {
+		return nil, fmt.Errorf("%w: expected %s", ErrTokenTypeInvalid, TokenTypeAccess)
+	}
+
+	jti, _ := access[ClaimJti].(string)
+	sid, _ := access[ClaimSessionID].(string)
+	revoked, err := s.store.IsRevoked(ctx, jti, sid)
+	if err != nil {
+		return nil, err
+	}
+	if revoked {
+		return nil, ErrTokenRevoked
+	}
+
+	return access, nil
+}
+
+func (s *TokenService) deny(ctx context.Context, access map[string]interface{}) error {
+	jti, _ := access[ClaimJti].(string)
+
+	ttl := s.accessExpire
+	if exp, err := jwt.MapClaims(access).GetExpirationTime(); err == nil && exp != nil {
+		ttl = time.Until(exp.Time)
+	}
+
+	return s.store.Deny(ctx, jti, ttl)
+}
+
+func (s *TokenService) signPair(sub, sid, refreshJti string, claims map[string]interface{}) (*TokenPair, error) {
+	now := time.Now()
+
+	access := make(map[string]interface{}, len(claims)+6)
+	maps.Copy(access, claims)
+	access[utils.JwtSub] = sub
+	access[ClaimJti] = utils.RandString()
+	access[ClaimSessionID] = sid
+	access[ClaimTokenType] = TokenTypeAccess
+	access[ClaimIat] = now.Unix()
+	access[utils.JwtExp] = now.Add(s.accessExpire).Unix()
+
+	accessToken, err := s.jwt.SignToken(access)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, err := s.jwt.SignToken(map[string]interface{}{
+		utils.JwtSub:   sub,
+		ClaimJti:       refreshJti,
+		ClaimSessionID: sid,
+		ClaimTokenType: TokenTypeRefresh,
+		ClaimIat:       now.Unix(),
+		utils.JwtExp:   now.Add(s.refreshExpire).Unix(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &TokenPair{
+		AccessToken:      accessToken,
+		RefreshToken:     refreshToken,
+		TokenType:        "Bearer",
+		ExpiresIn:        int64(s.accessExpire.Seconds()),
+		RefreshExpiresIn: int64(s.refreshExpire.Seconds()),
+	}, nil
+}
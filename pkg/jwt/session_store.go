@@ -0,0 +1,190 @@
+package jwt
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	sessionKeyPrefix = "jwt:session:"
+	subjectKeyPrefix = "jwt:subject:"
+	denyKeyPrefix    = "jwt:deny:"
+)
+
+var (
+	ErrNilRedisClient     = errors.New("jwt: redis client is nil")
+	ErrSessionRevoked     = errors.New("session revoked")
+	ErrRefreshTokenReused = errors.New("refresh token reused")
+)
+
+// SessionStore keeps the refresh token families, one per login session, and the
+// denied access tokens.
+type SessionStore interface {
+	// CreateSession starts the family sid of sub, jti is its current refresh token.
+	CreateSession(ctx context.Context, sid, sub, jti string, ttl time.Duration) error
+	// RotateSession replaces the current refresh token jti by nextJti. When jti is not
+	// the current one, the token was reused: the session is revoked and
+	// ErrRefreshTokenReused returned. ErrSessionRevoked is returned when sid is gone.
+	RotateSession(ctx context.Context, sid, jti, nextJti string, ttl time.Duration) error
+	RevokeSession(ctx context.Context, sid string) error
+	// RevokeSubject revokes all the sessions of sub.
+	RevokeSubject(ctx context.Context, sub string) error
+	// Deny revokes the access token jti until it expires.
+	Deny(ctx context.Context, jti string, ttl time.Duration) error
+	// IsRevoked reports whether jti is denied or its session sid revoked.
+	IsRevoked(ctx context.Context, jti, sid string) (bool, error)
+}
+
+var _ SessionStore = (*RedisSessionStore)(nil)
+
+// rotateScript returns 1 when rotated, 0 when jti was reused (the session is deleted)
+// and -1 when the session doesn't exist.
+var rotateScript = redis.NewScript(`
+local current = redis.call('HGET', KEYS[1], 'jti')
+if not current then
+	return -1
+end
+if current ~= ARGV[1] then
+	redis.call('DEL', KEYS[1])
+	return 0
+end
+redis.call('HSET', KEYS[1], 'jti', ARGV[2])
+redis.call('PEXPIRE', KEYS[1], ARGV[3])
+return 1
+`)
+
+type RedisSessionStore struct {
+	client redis.UniversalClient
+}
+
+func NewRedisSessionStore(client redis.UniversalClient) *RedisSessionStore {
+	return &RedisSessionStore{client: client}
+}
+
+func (s *RedisSessionStore) CreateSession(ctx context.Context, sid, sub, jti string, ttl time.Duration) error {
+	if s.client == nil {
+		return ErrNilRedisClient
+	}
+
+	// session and subject keys may be on different cluster slots, so no MULTI
+	_, err := s.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, sessionKeyPrefix+sid, "sub", sub, "jti", jti)
+		pipe.PExpire(ctx, sessionKeyPrefix+sid, ttl)
+		pipe.SAdd(ctx, subjectKeyPrefix+sub, sid)
+		pipe.PExpire(ctx, subjectKeyPrefix+sub, ttl)
+		return nil
+	})
+
+	return err
+}
+
+func (s *RedisSessionStore) RotateSession(ctx context.Context, sid, jti, nextJti string, ttl time.Duration) error {
+	if s.client == nil {
+		return ErrNilRedisClient
+	}
+
+	sub, err := s.client.HGet(ctx, sessionKeyPrefix+sid, "sub").Result()
+	if errors.Is(err, redis.Nil) {
+		return ErrSessionRevoked
+	}
+	if err != nil {
+		return err
+	}
+
+	res, err := rotateScript.Run(ctx, s.client, []string{sessionKeyPrefix + sid}, jti, nextJti, ttl.Milliseconds()).Int()
+	if err != nil {
+		return err
+	}
+
+	switch res {
+	case 1:
+		// the subject set lives as long as its latest session
+		return s.client.PExpire(ctx, subjectKeyPrefix+sub, ttl).Err()
+	case 0:
+		_ = s.client.SRem(ctx, subjectKeyPrefix+sub, sid).Err()
+		return ErrRefreshTokenReused
+	default:
+		return ErrSessionRevoked
+	}
+}
+
+func (s *RedisSessionStore) RevokeSession(ctx context.Context, sid string) error {
+	if s.client == nil {
+		return ErrNilRedisClient
+	}
+
+	sub, err := s.client.HGet(ctx, sessionKeyPrefix+sid, "sub").Result()
+	if errors.Is(err, redis.Nil) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := s.client.Del(ctx, sessionKeyPrefix+sid).Err(); err != nil {
+		return err
+	}
+
+	return s.client.SRem(ctx, subjectKeyPrefix+sub, sid).Err()
+}
+
+func (s *RedisSessionStore) RevokeSubject(ctx context.Context, sub string) error {
+	if s.client == nil {
+		return ErrNilRedisClient
+	}
+
+	sids, err := s.client.SMembers(ctx, subjectKeyPrefix+sub).Result()
+	if err != nil {
+		return err
+	}
+
+	_, err = s.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, sid := range sids {
+			pipe.Del(ctx, sessionKeyPrefix+sid)
+		}
+		pipe.Del(ctx, subjectKeyPrefix+sub)
+		return nil
+	})
+
+	return err
+}
+
+func (s *RedisSessionStore) Deny(ctx context.Context, jti string, ttl time.Duration) error {
+	if s.client == nil {
+		return ErrNilRedisClient
+	}
+
+	if ttl <= 0 {
+		// already expired
+		return nil
+	}
+
+	return s.client.Set(ctx, denyKeyPrefix+jti, time.Now().Unix(), ttl).Err()
+}
+
+func (s *RedisSessionStore) IsRevoked(ctx context.Context, jti, sid string) (bool, error) {
+	if s.client == nil {
+		return false, ErrNilRedisClient
+	}
+
+	var denied, session *redis.IntCmd
+	_, err := s.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		denied = pipe.Exists(ctx, denyKeyPrefix+jti)
+		if sid != "" {
+			session = pipe.Exists(ctx, sessionKeyPrefix+sid)
+		}
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+
+	if denied.Val() > 0 {
+		return true, nil
+	}
+
+	return session != nil && session.Val() == 0, nil
+}
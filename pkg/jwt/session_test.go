@@ -0,0 +1,137 @@
+package jwt
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type memorySession struct {
+	sub string
+	jti string
+}
+
+// memorySessionStore mirrors RedisSessionStore without expiry.
+type memorySessionStore struct {
+	mu       sync.Mutex
+	sessions map[string]memorySession
+	denied   map[string]bool
+}
+
+func newMemorySessionStore() *memorySessionStore {
+	return &memorySessionStore{sessions: make(map[string]memorySession), denied: make(map[string]bool)}
+}
+
+func (s *memorySessionStore) CreateSession(_ context.Context, sid, sub, jti string, _ time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sessions[sid] = memorySession{sub: sub, jti: jti}
+	return nil
+}
+
+func (s *memorySessionStore) RotateSession(_ context.Context, sid, jti, nextJti string, _ time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, ok := s.sessions[sid]
+	if !ok {
+		return ErrSessionRevoked
+	}
+	if session.jti != jti {
+		delete(s.sessions, sid)
+		return ErrRefreshTokenReused
+	}
+
+	session.jti = nextJti
+	s.sessions[sid] = session
+	return nil
+}
+
+func (s *memorySessionStore) RevokeSession(_ context.Context, sid string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.sessions, sid)
+	return nil
+}
+
+func (s *memorySessionStore) RevokeSubject(_ context.Context, sub string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for sid, session := range s.sessions {
+		if session.sub == sub {
+			delete(s.sessions, sid)
+		}
+	}
+	return nil
+}
+
+func (s *memorySessionStore) Deny(_ context.Context, jti string, _ time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.denied[jti] = true
+	return nil
+}
+
+func (s *memorySessionStore) IsRevoked(_ context.Context, jti, sid string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.sessions[sid]
+	return s.denied[jti] || !ok, nil
+}
+
+func TestTokenService_RefreshReuse(t *testing.T) {
+	ctx := context.Background()
+	j, _ := newTestJWT(t)
+	svc := NewTokenService(j, newMemorySessionStore())
+
+	pair, err := svc.IssuePair(ctx, "user", map[string]interface{}{"role": "admin"})
+	require.NoError(t, err)
+
+	var claims map[string]interface{}
+	require.NoError(t, svc.VerifyAndParseClaims(ctx, pair.AccessToken, &claims))
+	assert.Equal(t, "admin", claims["role"])
+
+	// a refresh token is not an access token
+	assert.ErrorIs(t, svc.VerifyAndParseClaims(ctx, pair.RefreshToken, &claims), ErrTokenTypeInvalid)
+
+	next, err := svc.Refresh(ctx, pair.RefreshToken, nil)
+	require.NoError(t, err)
+
+	// reusing the rotated refresh token revokes the whole family
+	_, err = svc.Refresh(ctx, pair.RefreshToken, nil)
+	assert.ErrorIs(t, err, ErrRefreshTokenReused)
+	assert.ErrorIs(t, err, ErrTokenRevoked)
+
+	_, err = svc.Refresh(ctx, next.RefreshToken, nil)
+	assert.ErrorIs(t, err, ErrSessionRevoked)
+	assert.ErrorIs(t, svc.VerifyAndParseClaims(ctx, next.AccessToken, &claims), ErrTokenRevoked)
+}
+
+func TestTokenService_Logout(t *testing.T) {
+	ctx := context.Background()
+	j, _ := newTestJWT(t)
+	svc := NewTokenService(j, newMemorySessionStore())
+
+	first, err := svc.IssuePair(ctx, "user", nil)
+	require.NoError(t, err)
+	second, err := svc.IssuePair(ctx, "user", nil)
+	require.NoError(t, err)
+	other, err := svc.IssuePair(ctx, "other", nil)
+	require.NoError(t, err)
+
+	var claims map[string]interface{}
+	require.NoError(t, svc.Revoke(ctx, first.AccessToken))
+	assert.ErrorIs(t, svc.VerifyAndParseClaims(ctx, first.AccessToken, &claims), ErrTokenRevoked)
+
+	// the session survives a revoked access token
+	_, err = svc.Refresh(ctx, first.RefreshToken, nil)
+	require.NoError(t, err)
+
+	require.NoError(t, svc.LogoutAll(ctx, "user"))
+	assert.ErrorIs(t, svc.VerifyAndParseClaims(ctx, second.AccessToken, &claims), ErrTokenRevoked)
+	require.NoError(t, svc.VerifyAndParseClaims(ctx, other.AccessToken, &claims))
+}
@@ -0,0 +1,192 @@
+package jwt
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Errors returned when verifying a token, check them with errors.Is.
+var (
+	ErrTokenMalformed            = jwt.ErrTokenMalformed
+	ErrTokenUnverifiable         = jwt.ErrTokenUnverifiable
+	ErrTokenSignatureInvalid     = jwt.ErrTokenSignatureInvalid
+	ErrTokenExpired              = jwt.ErrTokenExpired
+	ErrTokenNotValidYet          = jwt.ErrTokenNotValidYet
+	ErrTokenInvalidIssuer        = jwt.ErrTokenInvalidIssuer
+	ErrTokenInvalidAudience      = jwt.ErrTokenInvalidAudience
+	ErrTokenRequiredClaimMissing = jwt.ErrTokenRequiredClaimMissing
+	ErrTokenAlgNotAllowed        = errors.New("token signing method not allowed")
+)
+
+type validation struct {
+	issuers   []string
+	audiences []string
+	leeway    time.Duration
+	required  []string
+	algs      []string
+	now       func() time.Time
+}
+
+type ValidationOption func(*validation)
+
+// WithIssuer requires the iss claim to be one of issuers.
+func WithIssuer(issuers ...string) ValidationOption {
+	return func(v *validation) {
+		v.issuers = append(v.issuers, issuers...)
+	}
+}
+
+// WithAudience requires the aud claim to contain one of audiences.
+func WithAudience(audiences ...string) ValidationOption {
+	return func(v *validation) {
+		v.audiences = append(v.audiences, audiences...)
+	}
+}
+
+// WithLeeway allows a clock skew between the issuer and us when checking exp and nbf.
+func WithLeeway(leeway time.Duration) ValidationOption {
+	return func(v *validation) {
+		v.leeway = leeway
+	}
+}
+
+// WithRequiredClaims requires the claims to be present and not empty, e.g. exp or sub.
+func WithRequiredClaims(claims ...string) ValidationOption {
+	return func(v *validation) {
+		v.required = append(v.required, claims...)
+	}
+}
+
+// WithAllowedAlgs restricts the signing methods accepted.
+func WithAllowedAlgs(algs ...string) ValidationOption {
+	return func(v *validation) {
+		v.algs = append(v.algs, algs...)
+	}
+}
+
+// WithTimeFunc sets the clock used to check exp and nbf.
+func WithTimeFunc(now func() time.Time) ValidationOption {
+	return func(v *validation) {
+		v.now = now
+	}
+}
+
+func newValidation(opts []ValidationOption) *validation {
+	v := &validation{now: time.Now}
+	for _, opt := range opts {
+		opt(v)
+	}
+
+	return v
+}
+
+// ValidateClaims validates already parsed claims, exp and nbf are always checked when present.
+func ValidateClaims(claims map[string]interface{}, opts ...ValidationOption) error {
+	return newValidation(opts).validate(claims)
+}
+
+func (v *validation) checkAlg(alg string) error {
+	if len(v.algs) > 0 && !slices.Contains(v.algs, alg) {
+		return fmt.Errorf("%w: %s", ErrTokenAlgNotAllowed, alg)
+	}
+
+	return nil
+}
+
+func (v *validation) validate(claims jwt.MapClaims) error {
+	for _, name := range v.required {
+		if value, ok := claims[name]; !ok || value == nil || value == "" {
+			return fmt.Errorf("%w: %s", ErrTokenRequiredClaimMissing, name)
+		}
+	}
+
+	now := v.now()
+
+	exp, err := claims.GetExpirationTime()
+	if err != nil {
+		return fmt.Errorf("%w: exp: %v", ErrTokenMalformed, err)
+	}
+	if exp != nil && now.After(exp.Add(v.leeway)) {
+		return fmt.Errorf("%w: at %s", ErrTokenExpired, exp.Format(time.RFC3339))
+	}
+
+	nbf, err := claims.GetNotBefore()
+	if err != nil {
+		return fmt.Errorf("%w: nbf: %v", ErrTokenMalformed, err)
+	}
+	if nbf != nil && now.Add(v.leeway).Before(nbf.Time) {
+		return fmt.Errorf("%w: until %s", ErrTokenNotValidYet, nbf.Format(time.RFC3339))
+	}
+
+	if len(v.issuers) > 0 {
+		iss, err := claims.GetIssuer()
+		if err != nil {
+			return fmt.Errorf("%w: iss: %v", ErrTokenMalformed, err)
+		}
+		if !slices.Contains(v.issuers, iss) {
+			return fmt.Errorf("%w: %s", ErrTokenInvalidIssuer, iss)
+		}
+	}
+
+	if len(v.audiences) > 0 {
+		aud, err := claims.GetAudience()
+		if err != nil {
+			return fmt.Errorf("%w: aud: %v", ErrTokenMalformed, err)
+		}
+		if !slices.ContainsFunc(aud, func(a string) bool { return slices.Contains(v.audiences, a) }) {
+			return fmt.Errorf("%w: %v", ErrTokenInvalidAudience, aud)
+		}
+	}
+
+	return nil
+}
+
+// parseAndValidate verifies the token signature with the key of keyFunc, validates
+// its claims and parses them into claims when not nil.
+func parseAndValidate(tokenStr string, keyFunc jwt.Keyfunc, claims interface{}, opts []ValidationOption) error {
+	v := newValidation(opts)
+
+	// claims are validated by v, with leeway and typed errors
+	token, err := jwt.Parse(tokenStr, func(token *jwt.Token) (interface{}, error) {
+		if err := v.checkAlg(token.Method.Alg()); err != nil {
+			return nil, err
+		}
+
+		return keyFunc(token)
+	}, jwt.WithoutClaimsValidation())
+
+	if err != nil {
+		return err
+	}
+
+	// check token valid
+	if !token.Valid {
+		return fmt.Errorf("token invalid")
+	}
+
+	mapClaims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return fmt.Errorf("%w: claims", ErrTokenMalformed)
+	}
+
+	if err := v.validate(mapClaims); err != nil {
+		return err
+	}
+
+	if claims == nil {
+		return nil
+	}
+
+	// parse claims
+	byteClaim, err := json.Marshal(mapClaims)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(byteClaim, claims)
+}
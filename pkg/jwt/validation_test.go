@@ -0,0 +1,64 @@
+package jwt
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifyAndParseToken_Validation(t *testing.T) {
+	j, _ := newTestJWT(t)
+	now := time.Now()
+
+	token, err := j.SignToken(map[string]interface{}{
+		"sub": "user",
+		"iss": "issuer",
+		"aud": []string{"api", "web"},
+		"nbf": now.Unix(),
+		"exp": now.Add(time.Minute).Unix(),
+	})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name string
+		opts []ValidationOption
+		err  error
+	}{
+		{name: "valid", opts: []ValidationOption{WithIssuer("issuer"), WithAudience("api"), WithRequiredClaims("sub", "exp")}},
+		{name: "expired", opts: []ValidationOption{WithTimeFunc(func() time.Time { return now.Add(2 * time.Minute) })}, err: ErrTokenExpired},
+		{name: "expired within leeway", opts: []ValidationOption{WithLeeway(2 * time.Minute), WithTimeFunc(func() time.Time { return now.Add(2 * time.Minute) })}},
+		{name: "not valid yet", opts: []ValidationOption{WithTimeFunc(func() time.Time { return now.Add(-time.Minute) })}, err: ErrTokenNotValidYet},
+		{name: "issuer", opts: []ValidationOption{WithIssuer("other")}, err: ErrTokenInvalidIssuer},
+		{name: "audience", opts: []ValidationOption{WithAudience("admin")}, err: ErrTokenInvalidAudience},
+		{name: "required claim", opts: []ValidationOption{WithRequiredClaims("jti")}, err: ErrTokenRequiredClaimMissing},
+		{name: "alg", opts: []ValidationOption{WithAllowedAlgs(RS256)}, err: ErrTokenAlgNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var claims map[string]interface{}
+			err := j.VerifyAndParseToken(token, &claims, tt.opts...)
+			if tt.err != nil {
+				assert.ErrorIs(t, err, tt.err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, "user", claims["sub"])
+		})
+	}
+}
+
+func TestVerifyAndParseToken_SignatureInvalid(t *testing.T) {
+	j, _ := newTestJWT(t)
+	other, _ := newTestJWT(t)
+
+	token, err := other.SignToken(map[string]interface{}{"sub": "user"})
+	require.NoError(t, err)
+
+	var claims map[string]interface{}
+	assert.ErrorIs(t, j.VerifyAndParseToken(token, &claims), ErrTokenSignatureInvalid)
+	assert.ErrorIs(t, j.VerifyAndParseToken("not.a.token", &claims), ErrTokenMalformed)
+}
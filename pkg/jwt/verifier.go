@@ -0,0 +1,236 @@
+package jwt
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-source/pkg/client"
+	logger "go-source/pkg/log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	defaultJWKSCacheTTL      = 10 * time.Minute
+	defaultJWKSRefreshPeriod = time.Minute
+)
+
+var ErrKidRequired = errors.New("kid required")
+
+// KeySource fetches a JWKS.
+type KeySource interface {
+	Fetch(ctx context.Context) (JWKS, error)
+}
+
+type fileSource struct {
+	path string
+}
+
+// NewFileSource reads the JWKS from a JSON file, read again on every refresh.
+func NewFileSource(path string) KeySource {
+	return &fileSource{path: path}
+}
+
+func (s *fileSource) Fetch(_ context.Context) (JWKS, error) {
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return JWKS{}, err
+	}
+
+	var jwks JWKS
+	if err := json.Unmarshal(data, &jwks); err != nil {
+		return JWKS{}, err
+	}
+
+	return jwks, nil
+}
+
+type httpSource struct {
+	client *client.Client
+}
+
+// NewHTTPSource fetches the JWKS from url, usually the /.well-known/jwks.json of the issuer.
+func NewHTTPSource(url string, timeout time.Duration) KeySource {
+	return &httpSource{client: client.NewClient(url, timeout, 2, 200*time.Millisecond)}
+}
+
+func (s *httpSource) Fetch(ctx context.Context) (JWKS, error) {
+	var jwks JWKS
+	if _, err := s.client.R().SetContext(ctx).SetResult(&jwks).Get(""); err != nil {
+		return JWKS{}, err
+	}
+
+	return jwks, nil
+}
+
+type verifyKey struct {
+	alg string
+	key interface{}
+}
+
+// Verifier verifies tokens with the key of their kid in a JWKS. The keys are cached
+// for the TTL, and fetched again when a token has a kid not in the cache, at most
+// once per refresh period, so new keys are picked up as soon as the issuer rotates.
+type Verifier struct {
+	source        KeySource
+	ttl           time.Duration
+	refreshPeriod time.Duration
+
+	mu        sync.RWMutex
+	keys      map[string]verifyKey
+	fetchedAt time.Time
+
+	// fetchMu serializes fetches, concurrent misses share the same refresh
+	fetchMu     sync.Mutex
+	attemptedAt time.Time
+}
+
+type VerifierOption func(*Verifier)
+
+// WithCacheTTL sets how long the fetched keys are used before fetching them again.
+func WithCacheTTL(ttl time.Duration) VerifierOption {
+	return func(v *Verifier) {
+		v.ttl = ttl
+	}
+}
+
+// WithRefreshPeriod sets the minimum interval between two fetches triggered by tokens.
+func WithRefreshPeriod(period time.Duration) VerifierOption {
+	return func(v *Verifier) {
+		v.refreshPeriod = period
+	}
+}
+
+func NewVerifier(source KeySource, opts ...VerifierOption) *Verifier {
+	v := &Verifier{
+		source:        source,
+		ttl:           defaultJWKSCacheTTL,
+		refreshPeriod: defaultJWKSRefreshPeriod,
+		keys:          make(map[string]verifyKey),
+	}
+
+	for _, opt := range opts {
+		opt(v)
+	}
+
+	return v
+}
+
+// Refresh fetches the JWKS and replaces the cached keys. The cached keys are kept
+// when the fetch fails.
+func (v *Verifier) Refresh(ctx context.Context) error {
+	jwks, err := v.source.Fetch(ctx)
+	if err != nil {
+		return err
+	}
+
+	keys := make(map[string]verifyKey, len(jwks.Keys))
+	for _, k := range jwks.Keys {
+		if k.Use != "" && k.Use != UseSig {
+			continue
+		}
+
+		pubKey, err := k.PublicKey()
+		if err != nil {
+			logger.GetLogger().AddTraceInfoContextRequest(ctx).Warn().Err(err).Msgf("jwks key skipped: kid=%s", k.Kid)
+			continue
+		}
+
+		keys[k.Kid] = verifyKey{alg: k.Alg, key: pubKey}
+	}
+
+	v.mu.Lock()
+	v.keys = keys
+	v.fetchedAt = time.Now()
+	v.mu.Unlock()
+
+	return nil
+}
+
+// Key returns the public key and alg of kid, alg is empty when the JWK has none.
+func (v *Verifier) Key(ctx context.Context, kid string) (interface{}, string, error) {
+	if key, ok, fresh := v.cached(kid); ok && fresh {
+		return key.key, key.alg, nil
+	}
+
+	v.fetchMu.Lock()
+	defer v.fetchMu.Unlock()
+
+	// another goroutine may have refreshed while waiting
+	key, ok, fresh := v.cached(kid)
+	if ok && fresh {
+		return key.key, key.alg, nil
+	}
+
+	if v.refreshDue(ok) {
+		v.attemptedAt = time.Now()
+		if err := v.Refresh(ctx); err != nil {
+			logger.GetLogger().AddTraceInfoContextRequest(ctx).Err(err).Msg("jwks refresh failed")
+			if !ok {
+				return nil, "", err
+			}
+
+			// a stale key is better than rejecting every token while the source is down
+			return key.key, key.alg, nil
+		}
+
+		key, ok, _ = v.cached(kid)
+	}
+
+	if !ok {
+		return nil, "", fmt.Errorf("%w: kid=%s", ErrKeyNotFound, kid)
+	}
+
+	return key.key, key.alg, nil
+}
+
+// VerifyAndParseClaims verifies the token signature with the key of its kid, validates
+// its claims with opts and parses them.
+func (v *Verifier) VerifyAndParseClaims(ctx context.Context, tokenStr string, claims interface{}, opts ...ValidationOption) error {
+	return parseAndValidate(tokenStr, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		if kid == "" {
+			return nil, ErrKidRequired
+		}
+
+		pubKey, alg, err := v.Key(ctx, kid)
+		if err != nil {
+			return nil, err
+		}
+
+		// check signing method against the key, so a token can't pick a weaker alg
+		method := token.Method.Alg()
+		if (alg != "" && method != alg) || !algMatchesKey(method, pubKey) {
+			return nil, fmt.Errorf("%w: %v", ErrTokenAlgNotAllowed, method)
+		}
+
+		return pubKey, nil
+	}, claims, opts)
+}
+
+// cached returns the key of kid and whether the cache is still within its TTL.
+func (v *Verifier) cached(kid string) (verifyKey, bool, bool) {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	key, ok := v.keys[kid]
+	return key, ok, !v.fetchedAt.IsZero() && time.Since(v.fetchedAt) < v.ttl
+}
+
+// refreshDue reports whether the keys can be fetched: when expired or for an unknown
+// kid, at most once per refresh period so unknown kids or a down source can't flood it.
+// Must be called with fetchMu held.
+func (v *Verifier) refreshDue(found bool) bool {
+	if !v.attemptedAt.IsZero() && time.Since(v.attemptedAt) < v.refreshPeriod {
+		return false
+	}
+
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	return !found || v.fetchedAt.IsZero() || time.Since(v.fetchedAt) >= v.ttl
+}
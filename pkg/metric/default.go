@@ -12,4 +12,40 @@ var (
 	HttpClientMetricHistogram = NewGlobalHistogramInstrument(
 		"http_client", "Time to call http client",
 	)
+
+	RedisMetricHistogram = NewGlobalHistogramInstrument(
+		"redis", "Time to execute Redis commands",
+	)
+
+	RedisErrorCounter = NewGlobalCounterInstrument(
+		"redis_error", "Number of failed Redis commands",
+	)
+
+	RedisPoolGauge = NewGlobalGaugeInstrument(
+		"redis_pool", "Redis connection pool stats",
+	)
+
+	EventBusMetricHistogram = NewGlobalHistogramInstrument(
+		"event_bus", "Time to handle event bus events",
+	)
+
+	EventBusDropCounter = NewGlobalCounterInstrument(
+		"event_bus_drop", "Number of event bus events dropped because a subscriber buffer was full",
+	)
+
+	KafkaConsumerMetricHistogram = NewGlobalHistogramInstrument(
+		"kafka_consumer", "Time to handle Kafka messages",
+	)
+
+	KafkaConsumerErrorCounter = NewGlobalCounterInstrument(
+		"kafka_consumer_error", "Number of Kafka messages whose handler failed",
+	)
+
+	KafkaConsumerLagGauge = NewGlobalGaugeInstrument(
+		"kafka_consumer_lag", "Kafka consumer lag per topic partition",
+	)
+
+	KafkaDeliveryErrorCounter = NewGlobalCounterInstrument(
+		"kafka_delivery_error", "Number of Kafka messages that failed to be delivered",
+	)
 )
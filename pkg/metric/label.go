@@ -7,13 +7,19 @@ import (
 const (
 	InstrumentationName = "base_metric"
 
-	HttpComponent = "http"
+	HttpComponent     = "http"
+	RedisComponent    = "redis"
+	KafkaComponent    = "kafka"
+	EventBusComponent = "event_bus"
 )
 
 const (
 	ComponentAttr = "component"
 	MethodAttr    = "method"
 	CodeAttr      = "code"
+	StatAttr      = "stat"
+	TopicAttr     = "topic"
+	PartitionAttr = "partition"
 )
 
 var (
@@ -106,6 +106,18 @@ func (m *Metric) RecordCounter() error {
 	return nil
 }
 
+// RecordCounterWithAttributes is RecordCounter with the label as attributes.
+func (m *Metric) RecordCounterWithAttributes() error {
+	if m.f != nil {
+		if err := m.f(); err != nil {
+			return err
+		}
+	}
+
+	m.counter.Add(context.Background(), 1, meter.WithAttributes(m.label.GetAttributes()...))
+	return nil
+}
+
 func (m *Metric) RecordUpDownCounter() error {
 	if m.f != nil {
 		if err := m.f(); err != nil {
@@ -117,6 +129,18 @@ func (m *Metric) RecordUpDownCounter() error {
 	return nil
 }
 
+// RecordUpDownCounterWithAttributes is RecordUpDownCounter with the label as attributes.
+func (m *Metric) RecordUpDownCounterWithAttributes() error {
+	if m.f != nil {
+		if err := m.f(); err != nil {
+			return err
+		}
+	}
+
+	m.upDownCounter.Add(context.Background(), 1, meter.WithAttributes(m.label.GetAttributes()...))
+	return nil
+}
+
 func NewHistogram(histogram meter.Float64Histogram, component, method string) *Metric {
 	return NewMetric(WithLabel(WithComponent(component), WithMethod(method)), WithHistogram(histogram))
 }
@@ -133,6 +157,85 @@ func NewHistogramWithFunc(histogram meter.Float64Histogram, component, method st
 	return NewMetric(WithLabel(WithComponent(component), WithMethod(method)), WithHistogram(histogram), WithFunc(f)).Record()
 }
 
+func NewRedisHistogramDuration(method, code string, duration time.Duration) {
+	_ = NewMetric(
+		WithLabel(
+			WithComponent(RedisComponent),
+			WithMethod(method),
+			WithCode(code),
+		),
+		WithHistogram(RedisMetricHistogram),
+	).SetMillisDuration(duration).Record()
+}
+
+func NewRedisErrorCounter(method, code string) {
+	_ = NewMetric(
+		WithLabel(
+			WithComponent(RedisComponent),
+			WithMethod(method),
+			WithCode(code),
+		),
+		WithCounter(RedisErrorCounter),
+	).RecordCounterWithAttributes()
+}
+
+func NewEventBusHistogramDuration(method, code string, duration time.Duration) {
+	_ = NewMetric(
+		WithLabel(
+			WithComponent(EventBusComponent),
+			WithMethod(method),
+			WithCode(code),
+		),
+		WithHistogram(EventBusMetricHistogram),
+	).SetMillisDuration(duration).Record()
+}
+
+func NewEventBusDropCounter(method string) {
+	_ = NewMetric(
+		WithLabel(
+			WithComponent(EventBusComponent),
+			WithMethod(method),
+		),
+		WithCounter(EventBusDropCounter),
+	).RecordCounterWithAttributes()
+}
+
+func NewKafkaConsumerHistogramDuration(method, topic, code string, duration time.Duration) {
+	_ = NewMetric(
+		WithLabel(
+			WithComponent(KafkaComponent),
+			WithMethod(method),
+			WithCode(code),
+			WithAttributes(NewTags(TopicAttr, topic)),
+		),
+		WithHistogram(KafkaConsumerMetricHistogram),
+	).SetMillisDuration(duration).Record()
+}
+
+func NewKafkaConsumerErrorCounter(method, topic, code string) {
+	_ = NewMetric(
+		WithLabel(
+			WithComponent(KafkaComponent),
+			WithMethod(method),
+			WithCode(code),
+			WithAttributes(NewTags(TopicAttr, topic)),
+		),
+		WithCounter(KafkaConsumerErrorCounter),
+	).RecordCounterWithAttributes()
+}
+
+func NewKafkaDeliveryErrorCounter(topic, code string) {
+	_ = NewMetric(
+		WithLabel(
+			WithComponent(KafkaComponent),
+			WithMethod("produce"),
+			WithCode(code),
+			WithAttributes(NewTags(TopicAttr, topic)),
+		),
+		WithCounter(KafkaDeliveryErrorCounter),
+	).RecordCounterWithAttributes()
+}
+
 func NewMongoDBHistogramWithFunc(component, method string, f func() error) error {
 	return NewMetric(
 		WithLabel(
@@ -3,17 +3,83 @@ package middlewares
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"go-source/pkg/authz"
 	"go-source/pkg/client"
 	"go-source/pkg/jwt"
+	logger "go-source/pkg/log"
 	"go-source/pkg/utils"
 	"net/http"
 	"strings"
-	"time"
 
 	"github.com/labstack/echo/v4"
 )
 
-func Authorization(clientAuthorize *client.Client) echo.MiddlewareFunc {
+// TokenVerifier verifies a token locally, e.g. *jwt.Verifier with the JWKS of the issuer.
+type TokenVerifier interface {
+	VerifyAndParseClaims(ctx context.Context, tokenStr string, claims interface{}, opts ...jwt.ValidationOption) error
+}
+
+type AuthorizationConfig struct {
+	// Verifier verifies the token signature before calling the authorize service.
+	// Without it, the claims are only validated, the signature is left to the service.
+	Verifier TokenVerifier
+	// Validation validates the token claims, exp and nbf are always checked.
+	Validation []jwt.ValidationOption
+	// Engine authorizes the request locally from the verified claims, it requires
+	// Verifier. The authorize service, when set, decides the routes not in the policy.
+	// The requests it allows get X-Me-Profile from the profile claim of the token (see
+	// authz.WithProfileClaim), without that claim they have no profile.
+	Engine *authz.Engine
+	// Cache keeps the decisions of the authorize service for a short TTL, see WithDecisionCache.
+	Cache authz.DecisionCache
+}
+
+type AuthorizationOption func(cfg *AuthorizationConfig)
+
+func WithTokenVerifier(verifier TokenVerifier) AuthorizationOption {
+	return func(cfg *AuthorizationConfig) {
+		cfg.Verifier = verifier
+	}
+}
+
+func WithTokenValidation(opts ...jwt.ValidationOption) AuthorizationOption {
+	return func(cfg *AuthorizationConfig) {
+		cfg.Validation = append(cfg.Validation, opts...)
+	}
+}
+
+func WithPolicyEngine(engine *authz.Engine) AuthorizationOption {
+	return func(cfg *AuthorizationConfig) {
+		cfg.Engine = engine
+	}
+}
+
+// WithDecisionCache caches the decisions of the authorize service per token and request.
+// The token is verified before the cache is read, so with a *jwt.TokenService verifier
+// a revoked token is rejected on its next request. With another verifier, a token
+// revoked by the issuer keeps its cached allow decisions until the cache TTL expires.
+func WithDecisionCache(cache authz.DecisionCache) AuthorizationOption {
+	return func(cfg *AuthorizationConfig) {
+		cfg.Cache = cache
+	}
+}
+
+// Authorization authenticates the token then authorizes the request, with the policy
+// engine and/or the authorize service clientAuthorize, which may be nil with an engine.
+func Authorization(clientAuthorize *client.Client, opts ...AuthorizationOption) echo.MiddlewareFunc {
+	cfg := &AuthorizationConfig{}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	if cfg.Engine != nil && cfg.Verifier == nil {
+		// roles of unverified claims can't be trusted
+		logger.GetLogger().Warn().Msg("authorization: policy engine ignored, it requires a token verifier")
+		cfg.Engine = nil
+	}
+
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
 			ctx := c.Request().Context()
@@ -29,73 +95,60 @@ func Authorization(clientAuthorize *client.Client) echo.MiddlewareFunc {
 			}
 
 			// parse token
-			var claims map[string]interface{}
-			err := jwt.ParseClaims(strings.TrimPrefix(token, "Bearer "), &claims)
-			if err == nil {
-				// check expired
-				exp, ok := claims[utils.JwtExp].(int64)
-				if ok && time.Now().Unix() > exp {
-					return c.JSON(http.StatusUnauthorized, Resp{
-						ErrorCode:   ErrAuthentication,
-						Message:     "Authentication failed",
-						Description: "Token expired",
-					})
-				}
-
-				// get sub
-				sub, ok := claims[utils.JwtSub].(string)
-				if ok {
-					// set sub to context
-					ctx = context.WithValue(ctx, utils.JwtSub, sub)
-				}
+			var (
+				claims map[string]interface{}
+				err    error
+			)
+			token = strings.TrimPrefix(token, "Bearer ")
+			if cfg.Verifier != nil {
+				err = cfg.Verifier.VerifyAndParseClaims(ctx, token, &claims, cfg.Validation...)
+			} else if err = jwt.ParseClaims(token, &claims); err == nil {
+				err = jwt.ValidateClaims(claims, cfg.Validation...)
+			} else {
+				// a token we can't parse is left to the authorize service
+				claims, err = nil, nil
 			}
 
-			body := map[string]interface{}{
-				"type":   "API",
-				"method": c.Request().Method,
-				"url":    c.Request().URL.String(),
+			if err != nil {
+				logger.GetLogger().AddTraceInfoContextRequest(ctx).Info().Err(err).Msg("token invalid")
+				return tokenInvalid(c, err)
 			}
 
-			reqHeader := make(map[string][]string)
-			for k, v := range c.Request().Header {
-				reqHeader[k] = v
+			// get sub
+			if sub, ok := claims[utils.JwtSub].(string); ok {
+				// set sub to context
+				ctx = context.WithValue(ctx, utils.JwtSub, sub)
 			}
-			reqHeader["Content-Type"] = []string{"application/json"}
-			reqHeader["Accept-Encoding"] = []string{"gzip"}
 
-			// call authenticate and authorize: request header is c.Request().Header
-			res, err := clientAuthorize.R().SetContext(ctx).SetBody(body).SetHeaderMultiValues(reqHeader).Post("")
-			if err != nil {
-				var respBody Resp
-				if len(res.Body()) > 0 {
-					if err := json.Unmarshal(res.Body(), &respBody); err != nil {
-						return c.JSON(http.StatusUnauthorized, Resp{
-							ErrorCode:   ErrAuthentication,
-							Message:     "Authentication failed",
-							Description: err.Error(),
-						})
+			// evaluate the policy locally, the remote service decides the routes it doesn't cover
+			if cfg.Engine != nil {
+				decision := cfg.Engine.Authorize(cfg.Engine.Roles(claims), c.Request().Method, c.Request().URL.Path)
+				switch {
+				case decision.Allowed():
+					if profile := cfg.Engine.Profile(claims); profile != "" {
+						// set me to context
+						ctx = context.WithValue(ctx, utils.HeaderXMeProfile, profile)
 					}
-				}
+					c.SetRequest(c.Request().WithContext(ctx))
+					return next(c)
 
-				if respBody.Message == "" {
-					return c.JSON(http.StatusUnauthorized, Resp{
-						ErrorCode:   ErrAuthentication,
-						Message:     "Authentication failed",
-						Description: err.Error(),
+				case decision.Effect == authz.EffectDeny || clientAuthorize == nil:
+					return c.JSON(http.StatusForbidden, Resp{
+						ErrorCode:   ErrPermissionDenied,
+						Message:     "Permission denied",
+						Description: fmt.Sprintf("%s %s not allowed", c.Request().Method, c.Request().URL.Path),
 					})
 				}
+			}
 
-				return c.JSON(res.StatusCode(), Resp{
-					ErrorCode:   respBody.ErrorCode,
-					Message:     respBody.Message,
-					Description: respBody.Description,
-				})
+			decision := authorizeRemote(c, ctx, clientAuthorize, cfg.Cache, token)
+			if !decision.Allowed() {
+				return c.JSONBlob(decision.StatusCode, decision.Body)
 			}
 
-			me := res.Header().Get(utils.HeaderXMeProfile)
-			if me != "" {
+			if decision.Profile != "" {
 				// set me to context
-				ctx = context.WithValue(ctx, utils.HeaderXMeProfile, me)
+				ctx = context.WithValue(ctx, utils.HeaderXMeProfile, decision.Profile)
 			}
 
 			// set ctx to request context
@@ -105,3 +158,129 @@ func Authorization(clientAuthorize *client.Client) echo.MiddlewareFunc {
 		}
 	}
 }
+
+// authorizeRemote calls the authorize service, its decisions are cached when cache is set.
+func authorizeRemote(c echo.Context, ctx context.Context, clientAuthorize *client.Client, cache authz.DecisionCache, token string) *authz.Decision {
+	log := logger.GetLogger().AddTraceInfoContextRequest(ctx)
+
+	// the decision depends on the caller and the request
+	key := utils.Sha256HashHex([]byte(token + " " + c.Request().Method + " " + c.Request().URL.String()))
+	if cache != nil {
+		decision, err := cache.Get(ctx, key)
+		if err == nil {
+			return decision
+		}
+		if !errors.Is(err, authz.ErrDecisionNotFound) {
+			log.Warn().Err(err).Msg("authz get cached decision failed")
+		}
+	}
+
+	body := map[string]interface{}{
+		"type":   "API",
+		"method": c.Request().Method,
+		"url":    c.Request().URL.String(),
+	}
+
+	reqHeader := make(map[string][]string)
+	for k, v := range c.Request().Header {
+		reqHeader[k] = v
+	}
+	reqHeader["Content-Type"] = []string{"application/json"}
+	reqHeader["Accept-Encoding"] = []string{"gzip"}
+
+	// call authenticate and authorize: request header is c.Request().Header
+	res, err := clientAuthorize.R().SetContext(ctx).SetBody(body).SetHeaderMultiValues(reqHeader).Post("")
+	if err != nil {
+		var respBody Resp
+		if len(res.Body()) > 0 {
+			if err := json.Unmarshal(res.Body(), &respBody); err != nil {
+				return deniedDecision(http.StatusUnauthorized, Resp{
+					ErrorCode:   ErrAuthentication,
+					Message:     "Authentication failed",
+					Description: err.Error(),
+				})
+			}
+		}
+
+		if respBody.Message == "" {
+			return deniedDecision(http.StatusUnauthorized, Resp{
+				ErrorCode:   ErrAuthentication,
+				Message:     "Authentication failed",
+				Description: err.Error(),
+			})
+		}
+
+		decision := deniedDecision(res.StatusCode(), Resp{
+			ErrorCode:   respBody.ErrorCode,
+			Message:     respBody.Message,
+			Description: respBody.Description,
+		})
+
+		// only the answers of the service are cached, not its failures
+		if res.StatusCode() == http.StatusUnauthorized || res.StatusCode() == http.StatusForbidden {
+			cacheDecision(ctx, cache, key, decision)
+		}
+
+		return decision
+	}
+
+	decision := &authz.Decision{Effect: authz.EffectAllow, Profile: res.Header().Get(utils.HeaderXMeProfile)}
+	cacheDecision(ctx, cache, key, decision)
+
+	return decision
+}
+
+func deniedDecision(statusCode int, resp Resp) *authz.Decision {
+	body, _ := json.Marshal(resp)
+	return &authz.Decision{Effect: authz.EffectDeny, StatusCode: statusCode, Body: body}
+}
+
+func cacheDecision(ctx context.Context, cache authz.DecisionCache, key string, decision *authz.Decision) {
+	if cache == nil {
+		return
+	}
+
+	if err := cache.Set(ctx, key, decision); err != nil {
+		logger.GetLogger().AddTraceInfoContextRequest(ctx).Warn().Err(err).Msg("authz cache decision failed")
+	}
+}
+
+// tokenInvalid answers 401 with the reason the token was rejected.
+func tokenInvalid(c echo.Context, err error) error {
+	var description string
+	switch {
+	case errors.Is(err, jwt.ErrTokenRevoked):
+		description = "Token revoked"
+	case errors.Is(err, jwt.ErrTokenExpired):
+		description = "Token expired"
+	case errors.Is(err, jwt.ErrTokenNotValidYet):
+		description = "Token not valid yet"
+	case errors.Is(err, jwt.ErrTokenInvalidIssuer):
+		description = "Token issuer invalid"
+	case errors.Is(err, jwt.ErrTokenInvalidAudience):
+		description = "Token audience invalid"
+	case errors.Is(err, jwt.ErrTokenRequiredClaimMissing):
+		description = "Token required claim missing"
+	case errors.Is(err, jwt.ErrTokenTypeInvalid):
+		description = "Token type invalid"
+	case errors.Is(err, jwt.ErrTokenAlgNotAllowed):
+		description = "Token signing method not allowed"
+	case errors.Is(err, jwt.ErrKidRequired), errors.Is(err, jwt.ErrKeyNotFound):
+		description = "Token signing key unknown"
+	case errors.Is(err, jwt.ErrTokenSignatureInvalid):
+		description = "Token signature invalid"
+	case errors.Is(err, jwt.ErrTokenMalformed):
+		description = "Token malformed"
+	default:
+		description = "Token invalid"
+	}
+
+	c.Response().Header().Set(echo.HeaderWWWAuthenticate,
+		fmt.Sprintf(`Bearer error="invalid_token", error_description="%s"`, description))
+
+	return c.JSON(http.StatusUnauthorized, Resp{
+		ErrorCode:   ErrAuthentication,
+		Message:     "Authentication failed",
+		Description: description,
+	})
+}
@@ -0,0 +1,62 @@
+package middlewares
+
+import (
+	"context"
+	"encoding/json"
+	"go-source/pkg/authz"
+	"go-source/pkg/jwt"
+	logger "go-source/pkg/log"
+	"go-source/pkg/utils"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// staticVerifier accepts any token and returns its claims.
+type staticVerifier struct {
+	claims map[string]interface{}
+}
+
+func (v *staticVerifier) VerifyAndParseClaims(_ context.Context, _ string, claims interface{}, _ ...jwt.ValidationOption) error {
+	data, err := json.Marshal(v.claims)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, claims)
+}
+
+func TestAuthorization_EngineAllowSetsProfile(t *testing.T) {
+	logger.InitLog("test")
+
+	engine := authz.NewEngine(authz.NewStaticSource(&authz.Policy{Roles: []authz.Role{
+		{Name: "viewer", Permissions: []authz.Permission{{Method: "GET", Path: "/api/v1/users/:id"}}},
+	}}), authz.WithReloadInterval(0))
+	require.NoError(t, engine.Start(context.Background()))
+	defer engine.Stop()
+
+	verifier := &staticVerifier{claims: map[string]interface{}{
+		utils.JwtSub: "user-1",
+		"roles":      []string{"viewer"},
+		"profile":    "profile-1",
+	}}
+
+	var sub, profile interface{}
+	handler := Authorization(nil, WithTokenVerifier(verifier), WithPolicyEngine(engine))(func(c echo.Context) error {
+		sub = c.Request().Context().Value(utils.JwtSub)
+		profile = c.Request().Context().Value(utils.HeaderXMeProfile)
+		return c.NoContent(http.StatusOK)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/users/1", nil)
+	req.Header.Set("Authorization", "Bearer token")
+	rec := httptest.NewRecorder()
+	require.NoError(t, handler(echo.New().NewContext(req, rec)))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "user-1", sub)
+	assert.Equal(t, "profile-1", profile)
+}
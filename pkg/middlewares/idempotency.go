@@ -0,0 +1,259 @@
+package middlewares
+
+import (
+	"bytes"
+	"fmt"
+	"go-source/pkg/idempotency"
+	logger "go-source/pkg/log"
+	"go-source/pkg/utils"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	HeaderIdempotencyKey      = "Idempotency-Key"
+	HeaderIdempotentReplayed  = "Idempotent-Replayed"
+	idempotencyPollInterval   = 50 * time.Millisecond
+	defaultIdempotencyTTL     = 24 * time.Hour
+	defaultIdempotencyLockTTL = 30 * time.Second
+	defaultIdempotencyMaxBody = 1 << 20
+)
+
+type IdempotencyConfig struct {
+	// TTL is how long a completed response is replayed.
+	TTL time.Duration
+	// LockTTL bounds how long a crashed first request blocks its duplicates.
+	LockTTL time.Duration
+	// WaitTimeout is how long a concurrent duplicate waits for the first request
+	// before getting 409. Zero answers 409 immediately.
+	WaitTimeout time.Duration
+	// MaxBodySize is the largest body read to be fingerprinted, larger requests get 413.
+	MaxBodySize int64
+	// Required rejects requests without the Idempotency-Key header.
+	Required bool
+	// Principal scopes keys per caller, defaults to the JWT sub, then X-Me-Profile, as
+	// set to the context by Authorization. Requests without a principal are executed
+	// without idempotency, routes not behind Authorization need their own Principal.
+	Principal func(c echo.Context) string
+}
+
+type IdempotencyOption func(cfg *IdempotencyConfig)
+
+func WithIdempotencyTTL(ttl time.Duration) IdempotencyOption {
+	return func(cfg *IdempotencyConfig) {
+		cfg.TTL = ttl
+	}
+}
+
+func WithIdempotencyLockTTL(ttl time.Duration) IdempotencyOption {
+	return func(cfg *IdempotencyConfig) {
+		cfg.LockTTL = ttl
+	}
+}
+
+func WithIdempotencyWaitTimeout(timeout time.Duration) IdempotencyOption {
+	return func(cfg *IdempotencyConfig) {
+		cfg.WaitTimeout = timeout
+	}
+}
+
+func WithIdempotencyMaxBodySize(size int64) IdempotencyOption {
+	return func(cfg *IdempotencyConfig) {
+		cfg.MaxBodySize = size
+	}
+}
+
+func WithIdempotencyRequired() IdempotencyOption {
+	return func(cfg *IdempotencyConfig) {
+		cfg.Required = true
+	}
+}
+
+func WithIdempotencyPrincipal(fn func(c echo.Context) string) IdempotencyOption {
+	return func(cfg *IdempotencyConfig) {
+		cfg.Principal = fn
+	}
+}
+
+// Idempotency executes the first request carrying an Idempotency-Key and stores its
+// response; later requests with the same key and principal replay it. Responses
+// with status 5xx are not stored so the client can retry.
+func Idempotency(store idempotency.StoreInterface, opts ...IdempotencyOption) echo.MiddlewareFunc {
+	cfg := &IdempotencyConfig{
+		TTL:         defaultIdempotencyTTL,
+		LockTTL:     defaultIdempotencyLockTTL,
+		MaxBodySize: defaultIdempotencyMaxBody,
+		Principal:   idempotencyPrincipal,
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			ctx := c.Request().Context()
+			log := logger.GetLogger().AddTraceInfoContextRequest(ctx)
+
+			idemKey := c.Request().Header.Get(HeaderIdempotencyKey)
+			if idemKey == "" {
+				if cfg.Required {
+					return c.JSON(http.StatusBadRequest, Resp{
+						ErrorCode:   ErrDataInvalid,
+						Message:     "Data invalid",
+						Description: fmt.Sprintf("%s header is required", HeaderIdempotencyKey),
+					})
+				}
+				return next(c)
+			}
+
+			principal := cfg.Principal(c)
+			if principal == "" {
+				// keys can't be shared by callers nobody authenticated
+				log.Info().Msgf("idempotency skipped, no principal: key=%s", idemKey)
+				return next(c)
+			}
+
+			// Read and reset the body to fingerprint it
+			reqBody := []byte{}
+			if c.Request().Body != nil {
+				var err error
+				reqBody, err = io.ReadAll(http.MaxBytesReader(c.Response(), c.Request().Body, cfg.MaxBodySize))
+				if err != nil {
+					return readBodyFailed(c, err)
+				}
+			}
+			c.Request().Body = io.NopCloser(bytes.NewBuffer(reqBody))
+
+			fingerprint := utils.Sha256HashHex(append([]byte(c.Request().Method+" "+c.Request().URL.RequestURI()+"\n"), reqBody...))
+			storeKey := principal + ":" + idemKey
+
+			record, acquired, err := store.Acquire(ctx, storeKey, fingerprint, cfg.LockTTL)
+			if err != nil {
+				log.Error().Err(err).Msgf("idempotency acquire failed: key=%s", idemKey)
+				return c.JSON(http.StatusServiceUnavailable, Resp{
+					ErrorCode:   ErrIdempotency,
+					Message:     "Idempotency check failed",
+					Description: err.Error(),
+				})
+			}
+
+			if !acquired {
+				if record.Fingerprint != fingerprint {
+					return c.JSON(http.StatusUnprocessableEntity, Resp{
+						ErrorCode:   ErrIdempotencyKeyReused,
+						Message:     "Idempotency key reused",
+						Description: "Idempotency key was used with a different request",
+					})
+				}
+
+				if record.Status == idempotency.StatusProcessing {
+					record = waitIdempotencyRecord(c, store, storeKey, cfg.WaitTimeout)
+				}
+
+				if record == nil || record.Status != idempotency.StatusCompleted {
+					return c.JSON(http.StatusConflict, Resp{
+						ErrorCode:   ErrIdempotency,
+						Message:     "Request in progress",
+						Description: "A request with the same idempotency key is being processed",
+					})
+				}
+
+				return replayIdempotencyRecord(c, record)
+			}
+
+			token := record.Token
+			completed := false
+			defer func() {
+				if completed {
+					return
+				}
+				// handler failed or panicked, let the client retry
+				if err := store.Release(ctx, storeKey, token); err != nil {
+					log.Warn().Err(err).Msgf("idempotency release failed: key=%s", idemKey)
+				}
+			}()
+
+			resBody := new(bytes.Buffer)
+			mw := io.MultiWriter(c.Response().Writer, resBody)
+			c.Response().Writer = &bodyDumpResponseWriter{Writer: mw, ResponseWriter: c.Response().Writer}
+
+			// the error is rendered here to store its body, it is still returned to the
+			// outer middlewares; echo doesn't render a committed response twice
+			handlerErr := next(c)
+			if handlerErr != nil {
+				c.Error(handlerErr)
+			}
+
+			statusCode := c.Response().Status
+			if statusCode >= http.StatusInternalServerError {
+				return handlerErr
+			}
+
+			record.StatusCode = statusCode
+			record.Body = resBody.Bytes()
+			record.Header = map[string][]string{
+				echo.HeaderContentType: {c.Response().Header().Get(echo.HeaderContentType)},
+			}
+
+			if err := store.Complete(ctx, storeKey, record, cfg.TTL); err != nil {
+				log.Error().Err(err).Msgf("idempotency complete failed: key=%s", idemKey)
+				return handlerErr
+			}
+			completed = true
+
+			return handlerErr
+		}
+	}
+}
+
+func waitIdempotencyRecord(c echo.Context, store idempotency.StoreInterface, key string, timeout time.Duration) *idempotency.Record {
+	ctx := c.Request().Context()
+	deadline := time.Now().Add(timeout)
+
+	for time.Now().Before(deadline) {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(idempotencyPollInterval):
+		}
+
+		record, err := store.Get(ctx, key)
+		if err != nil {
+			// released by a failed first request
+			return nil
+		}
+
+		if record.Status == idempotency.StatusCompleted {
+			return record
+		}
+	}
+
+	return nil
+}
+
+func replayIdempotencyRecord(c echo.Context, record *idempotency.Record) error {
+	for k, values := range record.Header {
+		for _, v := range values {
+			c.Response().Header().Add(k, v)
+		}
+	}
+	c.Response().Header().Set(HeaderIdempotentReplayed, "true")
+
+	c.Response().WriteHeader(record.StatusCode)
+	_, err := c.Response().Write(record.Body)
+	return err
+}
+
+func idempotencyPrincipal(c echo.Context) string {
+	ctx := c.Request().Context()
+	if sub, ok := ctx.Value(utils.JwtSub).(string); ok && sub != "" {
+		return sub
+	}
+	if me, ok := ctx.Value(utils.HeaderXMeProfile).(string); ok && me != "" {
+		return me
+	}
+	return ""
+}
@@ -0,0 +1,158 @@
+package middlewares
+
+import (
+	"context"
+	"go-source/pkg/idempotency"
+	logger "go-source/pkg/log"
+	"go-source/pkg/utils"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+type memoryIdempotencyStore struct {
+	mu      sync.Mutex
+	records map[string]*idempotency.Record
+}
+
+func (s *memoryIdempotencyStore) Acquire(_ context.Context, key, fingerprint string, _ time.Duration) (*idempotency.Record, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if record, ok := s.records[key]; ok {
+		return record, false, nil
+	}
+	record := &idempotency.Record{Status: idempotency.StatusProcessing, Fingerprint: fingerprint}
+	s.records[key] = record
+	return record, true, nil
+}
+
+func (s *memoryIdempotencyStore) Get(_ context.Context, key string) (*idempotency.Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if record, ok := s.records[key]; ok {
+		return record, nil
+	}
+	return nil, idempotency.ErrRecordNotFound
+}
+
+func (s *memoryIdempotencyStore) Complete(_ context.Context, key string, record *idempotency.Record, _ time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	record.Status = idempotency.StatusCompleted
+	s.records[key] = record
+	return nil
+}
+
+func (s *memoryIdempotencyStore) Release(_ context.Context, key, _ string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.records, key)
+	return nil
+}
+
+func TestIdempotency(t *testing.T) {
+	logger.InitLog("test")
+
+	store := &memoryIdempotencyStore{records: map[string]*idempotency.Record{}}
+	calls := 0
+	handler := Idempotency(store)(func(c echo.Context) error {
+		calls++
+		return c.JSON(http.StatusCreated, map[string]int{"calls": calls})
+	})
+
+	do := func(key, body string) *httptest.ResponseRecorder {
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodPost, "/v1/points", strings.NewReader(body))
+		req = req.WithContext(context.WithValue(req.Context(), utils.JwtSub, "user-1"))
+		req.Header.Set(HeaderIdempotencyKey, key)
+		rec := httptest.NewRecorder()
+		assert.NoError(t, handler(e.NewContext(req, rec)))
+		return rec
+	}
+
+	t.Run("FIRST_REQUEST_EXECUTES", func(t *testing.T) {
+		rec := do("key-1", `{"amount":10}`)
+		assert.Equal(t, http.StatusCreated, rec.Code)
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("DUPLICATE_REPLAYS", func(t *testing.T) {
+		rec := do("key-1", `{"amount":10}`)
+		assert.Equal(t, http.StatusCreated, rec.Code)
+		assert.Equal(t, "true", rec.Header().Get(HeaderIdempotentReplayed))
+		assert.JSONEq(t, `{"calls":1}`, rec.Body.String())
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("FINGERPRINT_MISMATCH", func(t *testing.T) {
+		rec := do("key-1", `{"amount":20}`)
+		assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("IN_PROGRESS_CONFLICT", func(t *testing.T) {
+		fingerprint := store.records["user-1:key-1"].Fingerprint
+		_, _, _ = store.Acquire(context.Background(), "user-1:key-2", fingerprint, 0)
+
+		rec := do("key-2", `{"amount":10}`)
+		assert.Equal(t, http.StatusConflict, rec.Code)
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("BODY_TOO_LARGE", func(t *testing.T) {
+		small := Idempotency(store, WithIdempotencyMaxBodySize(4))(func(c echo.Context) error {
+			calls++
+			return c.NoContent(http.StatusCreated)
+		})
+
+		req := httptest.NewRequest(http.MethodPost, "/v1/points", strings.NewReader(`{"amount":10}`))
+		req = req.WithContext(context.WithValue(req.Context(), utils.JwtSub, "user-1"))
+		req.Header.Set(HeaderIdempotencyKey, "key-3")
+		rec := httptest.NewRecorder()
+		assert.NoError(t, small(echo.New().NewContext(req, rec)))
+		assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("NO_PRINCIPAL_SKIPPED", func(t *testing.T) {
+		// a client-supplied profile header is not a principal
+		for i := 0; i < 2; i++ {
+			req := httptest.NewRequest(http.MethodPost, "/v1/points", strings.NewReader(`{"amount":10}`))
+			req.Header.Set(HeaderIdempotencyKey, "key-1")
+			req.Header.Set(utils.HeaderXMeProfile, "user-1")
+			rec := httptest.NewRecorder()
+			assert.NoError(t, handler(echo.New().NewContext(req, rec)))
+			assert.Empty(t, rec.Header().Get(HeaderIdempotentReplayed))
+		}
+		assert.Equal(t, 3, calls)
+	})
+
+	t.Run("HANDLER_ERROR_RETURNED", func(t *testing.T) {
+		failing := Idempotency(store)(func(c echo.Context) error {
+			return echo.NewHTTPError(http.StatusBadRequest, "amount invalid")
+		})
+
+		for i := 0; i < 2; i++ {
+			req := httptest.NewRequest(http.MethodPost, "/v1/points", strings.NewReader(`{"amount":-1}`))
+			req = req.WithContext(context.WithValue(req.Context(), utils.JwtSub, "user-1"))
+			req.Header.Set(HeaderIdempotencyKey, "key-4")
+			rec := httptest.NewRecorder()
+			err := failing(echo.New().NewContext(req, rec))
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+			assert.Contains(t, rec.Body.String(), "amount invalid")
+			if i == 0 {
+				// the outer middlewares see the error of the handler
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, "true", rec.Header().Get(HeaderIdempotentReplayed))
+			}
+		}
+	})
+}
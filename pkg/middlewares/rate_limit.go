@@ -1,9 +1,14 @@
 package middlewares
 
 import (
+	"fmt"
 	"go-source/pkg/database/redis"
 	logger "go-source/pkg/log"
+	"go-source/pkg/ratelimit"
 	"go-source/pkg/utils"
+	"math"
+	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/go-redis/redis_rate/v10"
@@ -11,6 +16,13 @@ import (
 	"github.com/labstack/echo/v4"
 )
 
+const (
+	HeaderRateLimitLimit     = "RateLimit-Limit"
+	HeaderRateLimitRemaining = "RateLimit-Remaining"
+	HeaderRateLimitReset     = "RateLimit-Reset"
+	HeaderRetryAfter         = "Retry-After"
+)
+
 func RateLimit(period, rate int) echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
@@ -28,7 +40,12 @@ func RateLimit(period, rate int) echo.MiddlewareFunc {
 				log.Info().Msg("key-limit is empty")
 				return echo.ErrTooManyRequests
 			}
-			limiter := redis_rate.NewLimiter(redis.GetInstance().GetClient())
+			redisClient := redis.GetInstance().GetClient()
+			if redisClient == nil {
+				log.Error().Msg("redis client is nil")
+				return echo.ErrServiceUnavailable
+			}
+			limiter := redis_rate.NewLimiter(redisClient)
 			result, err := limiter.Allow(ctx, keyRateLimit.(string), redis_rate.Limit{
 				Rate:   rate,
 				Burst:  rate,
@@ -38,6 +55,14 @@ func RateLimit(period, rate int) echo.MiddlewareFunc {
 				return err
 			}
 
+			setRateLimitHeaders(c, &ratelimit.Result{
+				Allowed:    result.Allowed > 0,
+				Limit:      result.Limit.Burst,
+				Remaining:  result.Remaining,
+				RetryAfter: result.RetryAfter,
+				ResetAfter: result.ResetAfter,
+			})
+
 			if result.Allowed == 0 {
 				return echo.ErrTooManyRequests
 			}
@@ -46,3 +71,69 @@ func RateLimit(period, rate int) echo.MiddlewareFunc {
 		}
 	}
 }
+
+// RateLimitPolicies applies every policy to the request, in order. The first
+// policy that rejects the request ends the chain with 429; the response headers
+// describe the most restrictive policy evaluated. Each policy spends its quota when
+// evaluated, so a request denied by a later policy has still spent the quota of the
+// earlier ones; order the policies from the most to the least restrictive. A policy
+// that fails, e.g. its store is down without a local fallback, is logged and
+// skipped: the request fails open for that policy.
+func RateLimitPolicies(policies ...ratelimit.Policy) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			ctx := c.Request().Context()
+			log := logger.GetLogger().AddTraceInfoContextRequest(ctx)
+
+			var strictest *ratelimit.Result
+			for _, policy := range policies {
+				result, err := policy.Allow(ctx, c)
+				if err != nil {
+					log.Error().Err(err).Msgf("rate limit policy %s failed", policy.Name)
+					continue
+				}
+
+				if result == nil {
+					continue
+				}
+
+				if strictest == nil || !result.Allowed || result.Remaining < strictest.Remaining {
+					strictest = result
+				}
+
+				if !result.Allowed {
+					setRateLimitHeaders(c, result)
+					return c.JSON(http.StatusTooManyRequests, Resp{
+						ErrorCode:   ErrTooManyRequests,
+						Message:     "Too many requests",
+						Description: fmt.Sprintf("rate limit exceeded: policy=%s", policy.Name),
+					})
+				}
+			}
+
+			if strictest != nil {
+				setRateLimitHeaders(c, strictest)
+			}
+
+			return next(c)
+		}
+	}
+}
+
+func setRateLimitHeaders(c echo.Context, result *ratelimit.Result) {
+	header := c.Response().Header()
+	header.Set(HeaderRateLimitLimit, strconv.Itoa(result.Limit))
+	header.Set(HeaderRateLimitRemaining, strconv.Itoa(max(result.Remaining, 0)))
+	header.Set(HeaderRateLimitReset, strconv.Itoa(ceilSeconds(result.ResetAfter)))
+
+	if !result.Allowed {
+		header.Set(HeaderRetryAfter, strconv.Itoa(ceilSeconds(result.RetryAfter)))
+	}
+}
+
+func ceilSeconds(d time.Duration) int {
+	if d <= 0 {
+		return 0
+	}
+	return int(math.Ceil(d.Seconds()))
+}
@@ -76,7 +76,7 @@ func Logging(next echo.HandlerFunc) echo.HandlerFunc {
 		if path == "" {
 			path = "/"
 		}
-		if strings.Contains(path, "/health") {
+		if strings.Contains(path, "/health") || strings.Contains(path, "/ready") {
 			return nil
 		}
 
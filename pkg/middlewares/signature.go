@@ -0,0 +1,163 @@
+package middlewares
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	logger "go-source/pkg/log"
+	"go-source/pkg/signature"
+	"go-source/pkg/utils"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	HeaderClientID  = "X-Client-Id"
+	HeaderTimestamp = "X-Timestamp"
+	HeaderNonce     = "X-Nonce"
+	// HeaderSignature carries the hex signature of the canonical request.
+	HeaderSignature = utils.KeySignature
+
+	defaultSignatureWindow      = 5 * time.Minute
+	defaultSignatureMaxBodySize = 1 << 20
+)
+
+type SignatureConfig struct {
+	// Window is how far the request timestamp may be from now, in both directions.
+	Window time.Duration
+	// MaxBodySize is the largest body read to be hashed, larger requests get 413.
+	MaxBodySize int64
+}
+
+type SignatureOption func(cfg *SignatureConfig)
+
+func WithSignatureWindow(window time.Duration) SignatureOption {
+	return func(cfg *SignatureConfig) {
+		cfg.Window = window
+	}
+}
+
+func WithSignatureMaxBodySize(size int64) SignatureOption {
+	return func(cfg *SignatureConfig) {
+		cfg.MaxBodySize = size
+	}
+}
+
+// Signature verifies the signature of signature.CanonicalString of the request with
+// the key of its client. Requests outside the timestamp window or reusing a nonce
+// are rejected as replays. The client ID is set to the context on success.
+func Signature(keys signature.KeyStoreInterface, nonces signature.NonceStoreInterface, opts ...SignatureOption) echo.MiddlewareFunc {
+	cfg := &SignatureConfig{
+		Window:      defaultSignatureWindow,
+		MaxBodySize: defaultSignatureMaxBodySize,
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			ctx := c.Request().Context()
+			log := logger.GetLogger().AddTraceInfoContextRequest(ctx)
+			header := c.Request().Header
+
+			clientID := header.Get(HeaderClientID)
+			timestamp := header.Get(HeaderTimestamp)
+			nonce := header.Get(HeaderNonce)
+			sig := header.Get(HeaderSignature)
+			if clientID == "" || timestamp == "" || nonce == "" || sig == "" {
+				return checkSigFailed(c, fmt.Sprintf("%s, %s, %s and %s headers are required",
+					HeaderClientID, HeaderTimestamp, HeaderNonce, HeaderSignature))
+			}
+
+			ts, err := strconv.ParseInt(timestamp, 10, 64)
+			if err != nil {
+				return checkSigFailed(c, "Timestamp invalid")
+			}
+
+			if skew := time.Since(time.Unix(ts, 0)); skew > cfg.Window || skew < -cfg.Window {
+				return checkSigFailed(c, "Timestamp outside the allowed window")
+			}
+
+			key, err := keys.ClientKey(ctx, clientID)
+			if errors.Is(err, signature.ErrClientNotFound) {
+				return checkSigFailed(c, "Client unknown")
+			}
+			if err != nil {
+				log.Error().Err(err).Msgf("signature get client key failed: client=%s", clientID)
+				return c.JSON(http.StatusServiceUnavailable, Resp{
+					ErrorCode:   ErrCheckSig,
+					Message:     "Check signature failed",
+					Description: err.Error(),
+				})
+			}
+
+			// Read and reset the body to hash it
+			reqBody := []byte{}
+			if c.Request().Body != nil {
+				reqBody, err = io.ReadAll(http.MaxBytesReader(c.Response(), c.Request().Body, cfg.MaxBodySize))
+				if err != nil {
+					return readBodyFailed(c, err)
+				}
+			}
+			c.Request().Body = io.NopCloser(bytes.NewBuffer(reqBody))
+
+			message := signature.CanonicalString(c.Request().Method, c.Request().URL.Path, c.Request().URL.Query(), reqBody, timestamp, nonce)
+			if err := key.Verify(message, sig); err != nil {
+				log.Info().Err(err).Msgf("signature verify failed: client=%s", clientID)
+				return checkSigFailed(c, "Signature invalid")
+			}
+
+			// the nonce is recorded only once the signature is valid, so it can't be burnt
+			// by someone else; it is kept as long as its timestamp is accepted
+			fresh, err := nonces.Use(ctx, clientID, nonce, 2*cfg.Window)
+			if err != nil {
+				log.Error().Err(err).Msgf("signature use nonce failed: client=%s", clientID)
+				return c.JSON(http.StatusServiceUnavailable, Resp{
+					ErrorCode:   ErrCheckSig,
+					Message:     "Check signature failed",
+					Description: err.Error(),
+				})
+			}
+			if !fresh {
+				return checkSigFailed(c, "Nonce already used")
+			}
+
+			ctx = context.WithValue(ctx, HeaderClientID, clientID)
+			c.SetRequest(c.Request().WithContext(ctx))
+
+			return next(c)
+		}
+	}
+}
+
+func checkSigFailed(c echo.Context, description string) error {
+	return c.JSON(http.StatusUnauthorized, Resp{
+		ErrorCode:   ErrCheckSig,
+		Message:     "Check signature failed",
+		Description: description,
+	})
+}
+
+// readBodyFailed rejects a request whose body could not be read in full.
+func readBodyFailed(c echo.Context, err error) error {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		return c.JSON(http.StatusRequestEntityTooLarge, Resp{
+			ErrorCode:   ErrDataInvalid,
+			Message:     "Data invalid",
+			Description: fmt.Sprintf("Body larger than %d bytes", maxBytesErr.Limit),
+		})
+	}
+
+	return c.JSON(http.StatusBadRequest, Resp{
+		ErrorCode:   ErrDataInvalid,
+		Message:     "Data invalid",
+		Description: "Read body failed",
+	})
+}
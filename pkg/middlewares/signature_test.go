@@ -0,0 +1,132 @@
+package middlewares
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	logger "go-source/pkg/log"
+	"go-source/pkg/signature"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type memoryNonceStore struct {
+	mu   sync.Mutex
+	used map[string]bool
+}
+
+func (s *memoryNonceStore) Use(_ context.Context, clientID, nonce string, _ time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.used[clientID+":"+nonce] {
+		return false, nil
+	}
+	s.used[clientID+":"+nonce] = true
+	return true, nil
+}
+
+func TestSignature(t *testing.T) {
+	logger.InitLog("test")
+
+	edPub, edPriv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	rsaPubDer, err := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+	require.NoError(t, err)
+
+	keys, err := signature.NewStaticKeyStore(map[string]signature.ClientKey{
+		"partner-ed":  {Alg: signature.AlgEdDSA, PublicKey: hex.EncodeToString(edPub)},
+		"partner-rsa": {Alg: signature.AlgRSA, PublicKey: string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: rsaPubDer}))},
+	})
+	require.NoError(t, err)
+
+	handler := Signature(keys, &memoryNonceStore{used: map[string]bool{}})(func(c echo.Context) error {
+		return c.String(http.StatusOK, c.Request().Context().Value(HeaderClientID).(string))
+	})
+
+	sign := func(clientID, message string) string {
+		if clientID == "partner-ed" {
+			return hex.EncodeToString(ed25519.Sign(edPriv, []byte(message)))
+		}
+		hashed := sha256.Sum256([]byte(message))
+		sig, err := rsa.SignPKCS1v15(rand.Reader, rsaKey, crypto.SHA256, hashed[:])
+		require.NoError(t, err)
+		return hex.EncodeToString(sig)
+	}
+
+	do := func(clientID, nonce string, ts time.Time, signedBody, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/v1/transfers?b=2&a=1&a=0", strings.NewReader(body))
+		timestamp := strconv.FormatInt(ts.Unix(), 10)
+		message := signature.CanonicalString(http.MethodPost, "/v1/transfers", req.URL.Query(), []byte(signedBody), timestamp, nonce)
+
+		req.Header.Set(HeaderClientID, clientID)
+		req.Header.Set(HeaderTimestamp, timestamp)
+		req.Header.Set(HeaderNonce, nonce)
+		req.Header.Set(HeaderSignature, sign(clientID, message))
+
+		rec := httptest.NewRecorder()
+		assert.NoError(t, handler(echo.New().NewContext(req, rec)))
+		return rec
+	}
+
+	t.Run("VALID_EDDSA", func(t *testing.T) {
+		rec := do("partner-ed", "n1", time.Now(), `{"amount":10}`, `{"amount":10}`)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "partner-ed", rec.Body.String())
+	})
+
+	t.Run("VALID_RSA", func(t *testing.T) {
+		rec := do("partner-rsa", "n1", time.Now(), `{"amount":10}`, `{"amount":10}`)
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+
+	t.Run("REPLAYED_NONCE", func(t *testing.T) {
+		rec := do("partner-ed", "n1", time.Now(), `{"amount":10}`, `{"amount":10}`)
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+		assert.Contains(t, rec.Body.String(), "Nonce already used")
+	})
+
+	t.Run("TAMPERED_BODY", func(t *testing.T) {
+		rec := do("partner-ed", "n2", time.Now(), `{"amount":10}`, `{"amount":1000}`)
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+		assert.Contains(t, rec.Body.String(), "Signature invalid")
+
+		// the nonce of a rejected request is still usable
+		rec = do("partner-ed", "n2", time.Now(), `{"amount":10}`, `{"amount":10}`)
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+
+	t.Run("OUTSIDE_WINDOW", func(t *testing.T) {
+		rec := do("partner-ed", "n3", time.Now().Add(-10*time.Minute), "", "")
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+		assert.Contains(t, rec.Body.String(), "4921")
+	})
+
+	t.Run("BODY_TOO_LARGE", func(t *testing.T) {
+		body := strings.Repeat("a", defaultSignatureMaxBodySize+1)
+		rec := do("partner-ed", "n4", time.Now(), body, body)
+		assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+	})
+
+	t.Run("SHORT_KEY", func(t *testing.T) {
+		key := signature.ClientKey{Alg: signature.AlgEdDSA, PublicKey: "abcd"}
+		assert.Error(t, key.Verify("message", hex.EncodeToString(make([]byte, ed25519.SignatureSize))))
+	})
+}
@@ -15,6 +15,13 @@ const (
 
 	ErrIASAuthentication = 4020
 
+	ErrPermissionDenied = 4030
+
+	ErrIdempotency          = 4090
+	ErrIdempotencyKeyReused = 4220
+
+	ErrTooManyRequests = 4290
+
 	ErrRegionNotFound = 4911
 	ErrXTicketIdEmpty = 4912
 
@@ -0,0 +1,104 @@
+package dedup
+
+import (
+	"context"
+	"errors"
+	"go-source/pkg/database/mongodb"
+	"go-source/pkg/queue/kafka"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const collectionProcessedMessage = "processed_messages"
+
+var ErrNilCollection = errors.New("dedup: mongo collection is nil")
+
+var _ kafka.ProcessedStore = (*MongoStore)(nil)
+
+type ProcessedMessage struct {
+	ID          string    `json:"id" bson:"_id"`
+	ProcessedAt time.Time `json:"processedAt" bson:"processedAt"`
+}
+
+func (ProcessedMessage) CollectionName() string {
+	return collectionProcessedMessage
+}
+
+func (ProcessedMessage) IndexModels() []mongo.IndexModel {
+	return []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "processedAt", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(int32(DefaultTTL.Seconds())),
+		},
+	}
+}
+
+// MongoStore keeps the processed message IDs in a collection expiring them after
+// DefaultTTL. Handlers writing to Mongo use its ExecTransaction so the message is
+// marked processed in the same transaction as their writes.
+type MongoStore struct {
+	db         *mongodb.DatabaseStorage
+	collection *mongo.Collection
+}
+
+func NewMongoStore(db *mongodb.DatabaseStorage) *MongoStore {
+	repo := mongodb.NewRepository[ProcessedMessage](db)
+	return &MongoStore{db: db, collection: repo.Collection}
+}
+
+func (s *MongoStore) IsProcessed(ctx context.Context, id string) (bool, error) {
+	if s.collection == nil {
+		return false, ErrNilCollection
+	}
+
+	n, err := s.collection.CountDocuments(ctx, bson.M{"_id": id}, options.Count().SetLimit(1))
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+// MarkProcessed does nothing when the message was already marked, e.g. by ExecTransaction.
+func (s *MongoStore) MarkProcessed(ctx context.Context, id string) error {
+	if s.collection == nil {
+		return ErrNilCollection
+	}
+
+	_, err := s.collection.InsertOne(ctx, ProcessedMessage{ID: id, ProcessedAt: time.Now()})
+	if mongo.IsDuplicateKeyError(err) {
+		return nil
+	}
+	return err
+}
+
+// ExecTransaction runs callback in a transaction that also marks the message handled
+// with ctx as processed. When another consumer processed it concurrently the
+// transaction is aborted and kafka.ErrAlreadyProcessed is returned.
+func (s *MongoStore) ExecTransaction(ctx context.Context, callback func(sessCtx mongo.SessionContext) (interface{}, error)) error {
+	id := kafka.MessageIDFromContext(ctx)
+	if id == "" {
+		return s.db.ExecTransaction(ctx, callback)
+	}
+	if s.collection == nil {
+		return ErrNilCollection
+	}
+
+	return s.db.ExecTransaction(ctx, func(sessCtx mongo.SessionContext) (interface{}, error) {
+		res, err := callback(sessCtx)
+		if err != nil {
+			return nil, err
+		}
+
+		_, err = s.collection.InsertOne(sessCtx, ProcessedMessage{ID: id, ProcessedAt: time.Now()})
+		if mongo.IsDuplicateKeyError(err) {
+			return nil, kafka.ErrAlreadyProcessed
+		}
+		if err != nil {
+			return nil, err
+		}
+		return res, nil
+	})
+}
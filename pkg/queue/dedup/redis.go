@@ -0,0 +1,52 @@
+package dedup
+
+import (
+	"context"
+	"errors"
+	"go-source/pkg/queue/kafka"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	keyPrefix = "processed_message:"
+
+	DefaultTTL = 7 * 24 * time.Hour
+)
+
+var ErrNilRedisClient = errors.New("dedup: redis client is nil")
+
+var _ kafka.ProcessedStore = (*RedisStore)(nil)
+
+// RedisStore keeps the processed message IDs in Redis for ttl.
+type RedisStore struct {
+	client redis.UniversalClient
+	ttl    time.Duration
+}
+
+func NewRedisStore(client redis.UniversalClient, ttl time.Duration) *RedisStore {
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+	return &RedisStore{client: client, ttl: ttl}
+}
+
+func (s *RedisStore) IsProcessed(ctx context.Context, id string) (bool, error) {
+	if s.client == nil {
+		return false, ErrNilRedisClient
+	}
+
+	n, err := s.client.Exists(ctx, keyPrefix+id).Result()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+func (s *RedisStore) MarkProcessed(ctx context.Context, id string) error {
+	if s.client == nil {
+		return ErrNilRedisClient
+	}
+	return s.client.Set(ctx, keyPrefix+id, time.Now().Unix(), s.ttl).Err()
+}
@@ -0,0 +1,21 @@
+package event
+
+import (
+	"encoding/json"
+	"go-source/pkg/utils"
+	"time"
+)
+
+// Envelope wraps the data of every event published on a topic, Type and Version
+// select the Go type Data is decoded into.
+type Envelope struct {
+	ID      string `json:"id"`
+	Type    string `json:"type"`
+	Version int    `json:"version"`
+	Source  string `json:"source"`
+	// Instance identifies the process that published, replicas share the Source
+	Instance   string           `json:"instance,omitempty"`
+	OccurredAt time.Time        `json:"occurred_at"`
+	Trace      *utils.TraceInfo `json:"trace,omitempty"`
+	Data       json.RawMessage  `json:"data"`
+}
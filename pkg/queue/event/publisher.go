@@ -0,0 +1,32 @@
+package event
+
+import (
+	"context"
+	"go-source/pkg/queue/kafka"
+)
+
+// Publisher publishes registered events wrapped in an envelope.
+type Publisher struct {
+	producer kafka.PublisherInterface
+	registry *Registry
+	source   string
+}
+
+// NewPublisher creates a publisher, source names the service in the envelopes.
+func NewPublisher(producer kafka.PublisherInterface, registry *Registry, source string) *Publisher {
+	return &Publisher{
+		producer: producer,
+		registry: registry,
+		source:   source,
+	}
+}
+
+// Publish fails when the type of data is not registered or its schema is not
+// compatible with the stored one.
+func (p *Publisher) Publish(ctx context.Context, key string, data interface{}) error {
+	env, err := p.registry.NewEnvelope(ctx, p.source, data)
+	if err != nil {
+		return err
+	}
+	return p.producer.Publish(ctx, key, env)
+}
@@ -0,0 +1,172 @@
+package event
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-source/pkg/utils"
+	"reflect"
+	"sync"
+	"time"
+)
+
+var (
+	ErrDuplicateEventType = errors.New("event type already registered")
+	ErrUnknownEventType   = errors.New("unknown event type")
+)
+
+type typeVersion struct {
+	eventType string
+	version   int
+}
+
+type entry struct {
+	typeVersion
+	goType reflect.Type
+	schema *Schema
+	// err is set when the schema is not compatible with the stored one
+	err error
+}
+
+// Registry maps event types and versions to Go types. Each registered type has its
+// schema checked against the store, publishing a type whose check failed fails.
+type Registry struct {
+	store    SchemaStore
+	byType   map[typeVersion]*entry
+	byGoType map[reflect.Type]*entry
+	mu       sync.RWMutex
+}
+
+// NewRegistry creates a registry, store may be nil to skip the schema checks.
+func NewRegistry(store SchemaStore) *Registry {
+	return &Registry{
+		store:    store,
+		byType:   make(map[typeVersion]*entry),
+		byGoType: make(map[reflect.Type]*entry),
+	}
+}
+
+// Register maps eventType and version to T. A Go type maps to a single type and
+// version, the one NewEnvelope publishes, so registering T again under another one
+// fails with ErrDuplicateEventType; a new version needs a type of its own. It returns
+// an error when the schema of T is not compatible with the stored schema of the same
+// type and version, the type is registered anyway so the error is returned again
+// when publishing it.
+func Register[T any](r *Registry, eventType string, version int) error {
+	goType := reflect.TypeOf((*T)(nil)).Elem()
+	key := typeVersion{eventType: eventType, version: version}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if e, ok := r.byType[key]; ok {
+		if e.goType == goType {
+			return e.err
+		}
+		return fmt.Errorf("%w: %s v%d is %s", ErrDuplicateEventType, eventType, version, e.goType)
+	}
+	if e, ok := r.byGoType[goType]; ok {
+		return fmt.Errorf("%w: %s is %s v%d", ErrDuplicateEventType, goType, e.eventType, e.version)
+	}
+
+	e := &entry{typeVersion: key, goType: goType, schema: GenerateSchema(goType)}
+	e.schema.Title = eventType
+	if r.store != nil {
+		stored, err := r.store.Load(eventType, version)
+		if err != nil {
+			return err
+		}
+		if stored != nil {
+			if err = CheckCompatibility(stored, e.schema); err != nil {
+				e.err = fmt.Errorf("event %s v%d: %w", eventType, version, err)
+			}
+		}
+	}
+
+	r.byType[key] = e
+	r.byGoType[goType] = e
+	return e.err
+}
+
+// SaveSchemas stores the schemas of the registered types, to be run when a new
+// version is released so later changes are checked against it.
+func (r *Registry) SaveSchemas() error {
+	if r.store == nil {
+		return nil
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for key, e := range r.byType {
+		if e.err != nil {
+			return e.err
+		}
+		if err := r.store.Save(key.eventType, key.version, e.schema); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Schema returns the schema generated for the event type and version.
+func (r *Registry) Schema(eventType string, version int) (*Schema, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	e, ok := r.byType[typeVersion{eventType: eventType, version: version}]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s v%d", ErrUnknownEventType, eventType, version)
+	}
+	return e.schema, nil
+}
+
+// NewEnvelope wraps data, whose type must be registered, into an envelope.
+func (r *Registry) NewEnvelope(ctx context.Context, source string, data interface{}) (*Envelope, error) {
+	goType := reflect.TypeOf(data)
+	if goType != nil && goType.Kind() == reflect.Pointer {
+		goType = goType.Elem()
+	}
+
+	r.mu.RLock()
+	e, ok := r.byGoType[goType]
+	r.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %v", ErrUnknownEventType, goType)
+	}
+	if e.err != nil {
+		return nil, e.err
+	}
+
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Envelope{
+		ID:         utils.RandString(),
+		Type:       e.eventType,
+		Version:    e.version,
+		Source:     source,
+		OccurredAt: time.Now().UTC(),
+		Trace:      utils.GetRequestIdByContext(ctx),
+		Data:       raw,
+	}, nil
+}
+
+// Decode decodes the data of env into a new value of the registered type, as a pointer.
+func (r *Registry) Decode(env *Envelope) (interface{}, error) {
+	r.mu.RLock()
+	e, ok := r.byType[typeVersion{eventType: env.Type, version: env.Version}]
+	r.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %s v%d", ErrUnknownEventType, env.Type, env.Version)
+	}
+
+	data := reflect.New(e.goType).Interface()
+	if err := json.Unmarshal(env.Data, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
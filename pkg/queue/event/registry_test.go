@@ -0,0 +1,31 @@
+package event
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegister_Duplicate(t *testing.T) {
+	r := NewRegistry(nil)
+
+	require.NoError(t, Register[orderCreatedV1](r, "order.created", 1))
+	// registering again the same mapping is a no-op
+	require.NoError(t, Register[orderCreatedV1](r, "order.created", 1))
+
+	type orderCreatedV2 orderCreatedV1
+	assert.ErrorIs(t, Register[orderCreatedV2](r, "order.created", 1), ErrDuplicateEventType)
+	// the same Go type can't be published under two versions
+	assert.ErrorIs(t, Register[orderCreatedV1](r, "order.created", 2), ErrDuplicateEventType)
+	require.NoError(t, Register[orderCreatedV2](r, "order.created", 2))
+
+	env, err := r.NewEnvelope(context.Background(), "orders", orderCreatedV1{OrderID: "1"})
+	require.NoError(t, err)
+	assert.Equal(t, 1, env.Version)
+
+	env, err = r.NewEnvelope(context.Background(), "orders", &orderCreatedV2{OrderID: "1"})
+	require.NoError(t, err)
+	assert.Equal(t, 2, env.Version)
+}
@@ -0,0 +1,73 @@
+package event
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	logger "go-source/pkg/log"
+	"go-source/pkg/queue/kafka"
+	"reflect"
+	"sync"
+)
+
+type Handler[T any] func(ctx context.Context, env *Envelope, data *T) error
+
+// Router dispatches the envelopes read by a consumer to the handler of their type,
+// use it with consumer.OnEvent(router.OnEvent).
+type Router struct {
+	registry *Registry
+	handlers map[typeVersion]func(ctx context.Context, env *Envelope) error
+	mu       sync.RWMutex
+}
+
+func NewRouter(registry *Registry) *Router {
+	return &Router{
+		registry: registry,
+		handlers: make(map[typeVersion]func(ctx context.Context, env *Envelope) error),
+	}
+}
+
+// Handle sets the handler of eventType and version, which must be registered as T.
+func Handle[T any](r *Router, eventType string, version int, handler Handler[T]) error {
+	key := typeVersion{eventType: eventType, version: version}
+	goType := reflect.TypeOf((*T)(nil)).Elem()
+
+	r.registry.mu.RLock()
+	e, ok := r.registry.byType[key]
+	r.registry.mu.RUnlock()
+	if !ok || e.goType != goType {
+		return fmt.Errorf("%w: %s v%d as %s", ErrUnknownEventType, eventType, version, goType)
+	}
+
+	r.mu.Lock()
+	r.handlers[key] = func(ctx context.Context, env *Envelope) error {
+		var data T
+		if err := json.Unmarshal(env.Data, &data); err != nil {
+			return kafka.NonRetryable(err)
+		}
+		return handler(ctx, env, &data)
+	}
+	r.mu.Unlock()
+
+	return nil
+}
+
+// OnEvent is a kafka.OnEventHandler. Events without handler are skipped, malformed
+// events are not retried.
+func (r *Router) OnEvent(ctx context.Context, key, value []byte) error {
+	var env Envelope
+	if err := json.Unmarshal(value, &env); err != nil {
+		return kafka.NonRetryable(fmt.Errorf("decode event envelope: %w", err))
+	}
+
+	r.mu.RLock()
+	handler, ok := r.handlers[typeVersion{eventType: env.Type, version: env.Version}]
+	r.mu.RUnlock()
+	if !ok {
+		logger.GetLogger().AddTraceInfoContextRequest(ctx).Debug().
+			Msgf("event skipped, no handler: type=%s version=%d id=%s", env.Type, env.Version, env.ID)
+		return nil
+	}
+
+	return handler(ctx, &env)
+}
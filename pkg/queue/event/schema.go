@@ -0,0 +1,238 @@
+package event
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	TypeObject  = "object"
+	TypeArray   = "array"
+	TypeString  = "string"
+	TypeInteger = "integer"
+	TypeNumber  = "number"
+	TypeBoolean = "boolean"
+
+	FormatDateTime = "date-time"
+	FormatByte     = "byte"
+
+	schemaDraft = "https://json-schema.org/draft/2020-12/schema"
+)
+
+var ErrIncompatibleSchema = errors.New("incompatible schema")
+
+var (
+	timeType      = reflect.TypeOf(time.Time{})
+	marshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+)
+
+// Schema is the subset of JSON Schema generated from Go types. An empty Schema
+// accepts any value.
+type Schema struct {
+	Draft                string             `json:"$schema,omitempty"`
+	Title                string             `json:"title,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+}
+
+// GenerateSchema builds the JSON Schema of the JSON encoding of t. A field is
+// required unless it is a pointer or tagged omitempty.
+func GenerateSchema(t reflect.Type) *Schema {
+	schema := generate(t, map[reflect.Type]bool{})
+	schema.Draft = schemaDraft
+	return schema
+}
+
+func generate(t reflect.Type, seen map[reflect.Type]bool) *Schema {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	if t == timeType {
+		return &Schema{Type: TypeString, Format: FormatDateTime}
+	}
+	// custom encodings can not be described
+	if t.Implements(marshalerType) || reflect.PointerTo(t).Implements(marshalerType) {
+		return &Schema{}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: TypeBoolean}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: TypeInteger}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: TypeNumber}
+	case reflect.String:
+		return &Schema{Type: TypeString}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: TypeString, Format: FormatByte}
+		}
+		return &Schema{Type: TypeArray, Items: generate(t.Elem(), seen)}
+	case reflect.Map:
+		return &Schema{Type: TypeObject, AdditionalProperties: generate(t.Elem(), seen)}
+	case reflect.Struct:
+		if seen[t] {
+			// recursive type, the nested value is not described
+			return &Schema{Type: TypeObject}
+		}
+		seen[t] = true
+		defer delete(seen, t)
+
+		schema := &Schema{Type: TypeObject, Properties: map[string]*Schema{}}
+		addFields(schema, t, seen)
+		slices.Sort(schema.Required)
+		return schema
+	default:
+		return &Schema{}
+	}
+}
+
+func addFields(schema *Schema, t reflect.Type, seen map[reflect.Type]bool) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+
+		name, opts, _ := strings.Cut(tag, ",")
+		if field.Anonymous && name == "" {
+			ft := field.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				addFields(schema, ft, seen)
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		schema.Properties[name] = generate(field.Type, seen)
+		if field.Type.Kind() != reflect.Pointer && !slices.Contains(strings.Split(opts, ","), "omitempty") {
+			schema.Required = append(schema.Required, name)
+		}
+	}
+}
+
+// CheckCompatibility reports whether consumers built for old can read messages of
+// next: no field is removed or made optional while required, and no type changes.
+func CheckCompatibility(old, next *Schema) error {
+	var issues []string
+	checkCompatibility("$", old, next, &issues)
+	if len(issues) > 0 {
+		return fmt.Errorf("%w: %s", ErrIncompatibleSchema, strings.Join(issues, "; "))
+	}
+	return nil
+}
+
+func checkCompatibility(path string, old, next *Schema, issues *[]string) {
+	if old == nil || old.Type == "" {
+		return
+	}
+	if next == nil || next.Type == "" {
+		*issues = append(*issues, fmt.Sprintf("%s: type changed from %s to any", path, old.Type))
+		return
+	}
+
+	// an integer is a valid number
+	if old.Type != next.Type && !(old.Type == TypeNumber && next.Type == TypeInteger) {
+		*issues = append(*issues, fmt.Sprintf("%s: type changed from %s to %s", path, old.Type, next.Type))
+		return
+	}
+	if old.Format != "" && old.Format != next.Format {
+		*issues = append(*issues, fmt.Sprintf("%s: format changed from %s to %s", path, old.Format, next.Format))
+	}
+
+	for name, prop := range old.Properties {
+		propPath := path + "." + name
+		required := slices.Contains(old.Required, name)
+
+		nextProp, ok := next.Properties[name]
+		if !ok {
+			if required {
+				*issues = append(*issues, fmt.Sprintf("%s: required field removed", propPath))
+			}
+			continue
+		}
+		if required && !slices.Contains(next.Required, name) {
+			*issues = append(*issues, fmt.Sprintf("%s: field is no longer required", propPath))
+		}
+		checkCompatibility(propPath, prop, nextProp, issues)
+	}
+
+	if old.Items != nil {
+		checkCompatibility(path+"[]", old.Items, next.Items, issues)
+	}
+	if old.AdditionalProperties != nil {
+		checkCompatibility(path+"{}", old.AdditionalProperties, next.AdditionalProperties, issues)
+	}
+}
+
+// SchemaStore keeps the schemas already published, per event type and version.
+type SchemaStore interface {
+	Load(eventType string, version int) (*Schema, error)
+	Save(eventType string, version int, schema *Schema) error
+}
+
+// DirSchemaStore stores schemas as <dir>/<type>.v<version>.json files, meant to be
+// committed with the code so incompatible changes are caught before release.
+type DirSchemaStore struct {
+	dir string
+}
+
+func NewDirSchemaStore(dir string) *DirSchemaStore {
+	return &DirSchemaStore{dir: dir}
+}
+
+// Load returns nil when no schema was stored for the event type and version.
+func (s *DirSchemaStore) Load(eventType string, version int) (*Schema, error) {
+	data, err := os.ReadFile(s.path(eventType, version))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var schema Schema
+	if err = json.Unmarshal(data, &schema); err != nil {
+		return nil, err
+	}
+	return &schema, nil
+}
+
+func (s *DirSchemaStore) Save(eventType string, version int, schema *Schema) error {
+	data, err := json.MarshalIndent(schema, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err = os.MkdirAll(s.dir, 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(s.path(eventType, version), append(data, '\n'), 0o644)
+}
+
+func (s *DirSchemaStore) path(eventType string, version int) string {
+	return filepath.Join(s.dir, fmt.Sprintf("%s.v%d.json", eventType, version))
+}
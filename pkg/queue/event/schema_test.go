@@ -0,0 +1,65 @@
+package event
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type orderCreatedV1 struct {
+	OrderID   string    `json:"order_id"`
+	Amount    int64     `json:"amount"`
+	Note      string    `json:"note,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func TestGenerateSchema(t *testing.T) {
+	schema := GenerateSchema(reflect.TypeOf(orderCreatedV1{}))
+
+	assert.Equal(t, TypeObject, schema.Type)
+	assert.Equal(t, []string{"amount", "created_at", "order_id"}, schema.Required)
+	assert.Equal(t, FormatDateTime, schema.Properties["created_at"].Format)
+	assert.Equal(t, TypeInteger, schema.Properties["amount"].Type)
+}
+
+func TestCheckCompatibility(t *testing.T) {
+	old := GenerateSchema(reflect.TypeOf(orderCreatedV1{}))
+
+	// new optional and required fields are fine
+	type added struct {
+		orderCreatedV1
+		Currency string   `json:"currency"`
+		Tags     []string `json:"tags,omitempty"`
+	}
+	assert.NoError(t, CheckCompatibility(old, GenerateSchema(reflect.TypeOf(added{}))))
+
+	// an optional field can be removed
+	type noteRemoved struct {
+		OrderID   string    `json:"order_id"`
+		Amount    int64     `json:"amount"`
+		CreatedAt time.Time `json:"created_at"`
+	}
+	assert.NoError(t, CheckCompatibility(old, GenerateSchema(reflect.TypeOf(noteRemoved{}))))
+
+	type amountChanged struct {
+		OrderID   string    `json:"order_id"`
+		Amount    string    `json:"amount"`
+		CreatedAt time.Time `json:"created_at"`
+	}
+	assert.ErrorIs(t, CheckCompatibility(old, GenerateSchema(reflect.TypeOf(amountChanged{}))), ErrIncompatibleSchema)
+
+	type orderRemoved struct {
+		Amount    int64     `json:"amount"`
+		CreatedAt time.Time `json:"created_at"`
+	}
+	assert.ErrorIs(t, CheckCompatibility(old, GenerateSchema(reflect.TypeOf(orderRemoved{}))), ErrIncompatibleSchema)
+
+	type orderOptional struct {
+		OrderID   *string   `json:"order_id"`
+		Amount    int64     `json:"amount"`
+		CreatedAt time.Time `json:"created_at"`
+	}
+	assert.ErrorIs(t, CheckCompatibility(old, GenerateSchema(reflect.TypeOf(orderOptional{}))), ErrIncompatibleSchema)
+}
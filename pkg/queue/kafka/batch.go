@@ -0,0 +1,362 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	logger "go-source/pkg/log"
+	"go-source/pkg/utils"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+)
+
+const (
+	defaultBatchSize = 100
+	defaultBatchWait = 500 * time.Millisecond
+)
+
+// Message is one message of a batch. Ctx carries the trace info of the message,
+// ID is set when dedup is enabled.
+type Message struct {
+	Ctx       context.Context
+	ID        string
+	Topic     string
+	Partition int32
+	Offset    int64
+	Key       []byte
+	Value     []byte
+	Headers   []kafka.Header
+	Timestamp time.Time
+}
+
+type OnBatchHandler func(ctx context.Context, msgs []Message) error
+
+// BatchError is returned by a batch handler when only some messages failed.
+// Failed maps the index of a message in the batch to its error; the other
+// messages are considered processed.
+type BatchError struct {
+	Failed map[int]error
+}
+
+func NewBatchError() *BatchError {
+	return &BatchError{Failed: make(map[int]error)}
+}
+
+func (e *BatchError) Add(index int, err error) {
+	e.Failed[index] = err
+}
+
+func (e *BatchError) Error() string {
+	return fmt.Sprintf("batch failed: %d messages", len(e.Failed))
+}
+
+// WithBatch sets how many messages are collected, at most, before calling the batch
+// handler, and how long to wait for them after the first message arrived.
+func WithBatch(size int, wait time.Duration) ConsumerOption {
+	return func(s *Consumer) {
+		s.batchSize = size
+		s.batchWait = wait
+	}
+}
+
+// OnBatch switches the consumer to batch mode, the handler set by OnEvent is not used.
+func (s *Consumer) OnBatch(handler OnBatchHandler) {
+	s.mu.Lock()
+	if handler != nil {
+		s.batchHandler = handler
+	}
+	s.mu.Unlock()
+}
+
+func (s *Consumer) consumeBatches(ctx context.Context) error {
+	if s.batchSize <= 0 {
+		s.batchSize = defaultBatchSize
+	}
+	if s.batchWait <= 0 {
+		s.batchWait = defaultBatchWait
+	}
+
+	for {
+		// the batch collected when ctx is done is still processed, so it is drained
+		batch := s.collectBatch(ctx)
+		if len(batch) > 0 {
+			s.processBatch(ctx, batch)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		default:
+		}
+	}
+}
+
+// collectBatch reads the next batch into s.batch, so a revoke during ReadMessage can
+// drop the messages of the revoked partitions.
+func (s *Consumer) collectBatch(ctx context.Context) []*kafka.Message {
+	log := logger.GetLogger()
+
+	var deadline time.Time
+	s.batch = nil
+
+	for len(s.batch) < s.batchSize && ctx.Err() == nil {
+		s.resumeDue()
+
+		timeout := pollTimeout
+		if len(s.batch) > 0 {
+			timeout = time.Until(deadline)
+			if timeout <= 0 {
+				break
+			}
+		}
+
+		msg, err := s.cs.ReadMessage(timeout)
+		if err != nil {
+			var kerr kafka.Error
+			if errors.As(err, &kerr); kerr.Code() == kafka.ErrTimedOut {
+				continue
+			}
+			log.Warn().Err(err).Msg("kafka read message failed")
+			continue
+		}
+
+		if s.retry != nil && s.delayIfNotDue(msg) {
+			continue
+		}
+
+		if len(s.batch) == 0 {
+			deadline = time.Now().Add(s.batchWait)
+		}
+		s.batch = append(s.batch, msg)
+	}
+
+	batch := s.batch
+	s.batch = nil
+	return batch
+}
+
+// dropBatch removes the messages of the revoked partitions from the batch being
+// collected, the new owner reads them again from the committed offset.
+func (s *Consumer) dropBatch(partitions []kafka.TopicPartition) {
+	revoked := partitionSet(partitions)
+
+	remaining := s.batch[:0]
+	for _, msg := range s.batch {
+		if !revoked[partitionKey{topic: *msg.TopicPartition.Topic, partition: msg.TopicPartition.Partition}] {
+			remaining = append(remaining, msg)
+		}
+	}
+
+	if dropped := len(s.batch) - len(remaining); dropped > 0 {
+		logger.GetLogger().Info().Msgf("kafka batch dropped %d messages of revoked partitions: %v", dropped, partitions)
+	}
+	s.batch = remaining
+}
+
+// processBatch runs the batch handler, routes the failed messages to retry/DLQ and
+// commits the offsets of the whole batch in a single commit.
+func (s *Consumer) processBatch(ctx context.Context, batch []*kafka.Message) {
+	offsets := batchOffsets(batch)
+	if s.dedup != nil {
+		if batch = s.skipProcessed(ctx, batch); len(batch) == 0 {
+			s.commitBatch(ctx, offsets)
+			return
+		}
+	}
+
+	msgs := make([]Message, len(batch))
+	for i, msg := range batch {
+		msgs[i] = Message{
+			Ctx:       messageContext(ctx, msg),
+			Topic:     *msg.TopicPartition.Topic,
+			Partition: msg.TopicPartition.Partition,
+			Offset:    int64(msg.TopicPartition.Offset),
+			Key:       msg.Key,
+			Value:     msg.Value,
+			Headers:   msg.Headers,
+			Timestamp: msg.Timestamp,
+		}
+		if s.dedup != nil {
+			msgs[i].ID = MessageID(msg)
+			msgs[i].Ctx = contextWithMessageID(msgs[i].Ctx, msgs[i].ID)
+		}
+	}
+
+	batchCtx, _ := utils.NewContextWithRequestId(context.WithoutCancel(ctx))
+	log := logger.GetLogger().AddTraceInfoContextRequest(batchCtx)
+	log.Info().
+		Int("size", len(batch)).
+		Interface("firstTopicPartition", batch[0].TopicPartition).
+		Interface("lastTopicPartition", batch[len(batch)-1].TopicPartition).
+		Msg("kafka read batch success")
+
+	attempts, err := s.handleBatchWithRetry(ctx, batchCtx, msgs)
+
+	var batchErr *BatchError
+	isPartial := errors.As(err, &batchErr)
+	if err == nil || isPartial {
+		s.markBatchProcessed(msgs, batchErr)
+	}
+
+	if err != nil {
+		log.Err(err).Msg("kafka batch handlers failed")
+		if s.retry == nil {
+			return
+		}
+
+		for i, msg := range batch {
+			msgErr := err
+			if isPartial {
+				if msgErr = batchErr.Failed[i]; msgErr == nil {
+					continue
+				}
+			}
+
+			if !s.routeUntilDone(ctx, msgs[i].Ctx, msg, attempts, msgErr) {
+				return
+			}
+		}
+	}
+
+	s.commitBatch(batchCtx, offsets)
+}
+
+// commitBatch commits the offsets of the partitions still assigned, the others
+// belong to another consumer now.
+func (s *Consumer) commitBatch(ctx context.Context, offsets []kafka.TopicPartition) {
+	log := logger.GetLogger().AddTraceInfoContextRequest(ctx)
+
+	assignment, err := s.cs.Assignment()
+	if err != nil {
+		log.Err(err).Msgf("kafka get assignment failed, batch not committed: %v", offsets)
+		return
+	}
+
+	assigned := partitionSet(assignment)
+	owned := make([]kafka.TopicPartition, 0, len(offsets))
+	for _, tp := range offsets {
+		if assigned[partitionKey{topic: *tp.Topic, partition: tp.Partition}] {
+			owned = append(owned, tp)
+		}
+	}
+	if len(owned) < len(offsets) {
+		log.Warn().Msgf("kafka batch offsets of unassigned partitions not committed: %v", offsets)
+	}
+	if len(owned) == 0 {
+		return
+	}
+
+	if _, err = s.cs.CommitOffsets(owned); err != nil {
+		log.Err(err).Msgf("kafka commit batch failed: %v", owned)
+	}
+}
+
+// skipProcessed removes the messages dedup has already processed from the batch.
+func (s *Consumer) skipProcessed(ctx context.Context, batch []*kafka.Message) []*kafka.Message {
+	log := logger.GetLogger()
+
+	res := make([]*kafka.Message, 0, len(batch))
+	for _, msg := range batch {
+		id := MessageID(msg)
+		processed, err := s.dedup.IsProcessed(ctx, id)
+		if err != nil {
+			log.Warn().Err(err).Msgf("kafka check processed message failed: id=%s", id)
+		}
+		if processed {
+			log.Info().Msgf("kafka message already processed, skipped: id=%s", id)
+			continue
+		}
+		res = append(res, msg)
+	}
+	return res
+}
+
+func (s *Consumer) markBatchProcessed(msgs []Message, batchErr *BatchError) {
+	if s.dedup == nil {
+		return
+	}
+
+	for i, msg := range msgs {
+		if batchErr != nil && batchErr.Failed[i] != nil {
+			continue
+		}
+		if err := s.dedup.MarkProcessed(msg.Ctx, msg.ID); err != nil {
+			logger.GetLogger().AddTraceInfoContextRequest(msg.Ctx).Warn().Err(err).
+				Msgf("kafka mark message processed failed: id=%s", msg.ID)
+		}
+	}
+}
+
+// handleBatchWithRetry retries the whole batch in place, a partial failure is not retried.
+func (s *Consumer) handleBatchWithRetry(ctx, batchCtx context.Context, msgs []Message) (int, error) {
+	maxAttempts := 1
+	if s.retry != nil && s.retry.MaxAttempts > 1 {
+		maxAttempts = s.retry.MaxAttempts
+	}
+
+	var (
+		err    error
+		waited time.Duration
+	)
+	attempt := 0
+	for attempt < maxAttempts {
+		attempt++
+		start := time.Now()
+		err = s.batchHandler(batchCtx, msgs)
+		recordHandler(methodBatch, batchTopic(msgs), time.Since(start), err)
+
+		var batchErr *BatchError
+		if err == nil || errors.Is(err, ErrNonRetryable) || errors.As(err, &batchErr) {
+			return attempt, err
+		}
+
+		if attempt < maxAttempts {
+			backoff, ok := s.retry.inPlaceWait(attempt, waited)
+			if !ok {
+				return attempt, err
+			}
+			waited += backoff
+
+			select {
+			case <-ctx.Done():
+				return attempt, err
+			case <-time.After(backoff):
+			}
+		}
+	}
+
+	return attempt, err
+}
+
+// batchOffsets returns, per partition, the offset after the last message of the batch.
+func batchOffsets(batch []*kafka.Message) []kafka.TopicPartition {
+	last := make(map[partitionKey]kafka.Offset)
+	for _, msg := range batch {
+		key := partitionKey{topic: *msg.TopicPartition.Topic, partition: msg.TopicPartition.Partition}
+		if offset, ok := last[key]; !ok || msg.TopicPartition.Offset > offset {
+			last[key] = msg.TopicPartition.Offset
+		}
+	}
+
+	offsets := make([]kafka.TopicPartition, 0, len(last))
+	for key, offset := range last {
+		topic := key.topic
+		offsets = append(offsets, kafka.TopicPartition{Topic: &topic, Partition: key.partition, Offset: offset + 1})
+	}
+
+	return offsets
+}
+
+// batchTopic returns the topic of msgs, empty when they come from several topics.
+func batchTopic(msgs []Message) string {
+	if len(msgs) == 0 {
+		return ""
+	}
+	for _, msg := range msgs[1:] {
+		if msg.Topic != msgs[0].Topic {
+			return ""
+		}
+	}
+	return msgs[0].Topic
+}
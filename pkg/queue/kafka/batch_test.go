@@ -0,0 +1,61 @@
+package kafka_test
+
+import (
+	"context"
+	logger "go-source/pkg/log"
+	"go-source/pkg/queue/kafka"
+	"go-source/pkg/queue/kafka/kafkatest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConsumer_BatchRevoke(t *testing.T) {
+	logger.InitLog("test")
+
+	const topic = "orders"
+	broker := kafkatest.NewBroker(kafkatest.WithPartitions(2))
+	producer := broker.NewProducer(topic)
+	for i := 0; i < 4; i++ {
+		require.NoError(t, producer.PublishWithPartition(context.Background(), "key", i, int32(i%2)))
+	}
+
+	var (
+		handled []kafka.Message
+		mu      sync.Mutex
+	)
+	consumer := kafka.NewConsumerWithClient(broker.NewClient("group"), []string{topic}, kafka.WithBatch(10, 500*time.Millisecond))
+	consumer.OnBatch(func(ctx context.Context, msgs []kafka.Message) error {
+		mu.Lock()
+		handled = append(handled, msgs...)
+		mu.Unlock()
+		return nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		_ = consumer.Start(ctx)
+	}()
+
+	// the batch holds the 4 messages when a second member takes partition 1
+	time.Sleep(100 * time.Millisecond)
+	require.NoError(t, broker.NewClient("group").SubscribeTopics([]string{topic}, nil))
+
+	assert.Eventually(t, func() bool {
+		return broker.Committed("group", topic, 0) == 2
+	}, 2*time.Second, 10*time.Millisecond)
+	consumer.Shutdown(context.Background())
+
+	// the messages of partition 1 went with it: not handled, not committed
+	assert.Zero(t, broker.Committed("group", topic, 1))
+	mu.Lock()
+	defer mu.Unlock()
+	require.Len(t, handled, 2)
+	for _, msg := range handled {
+		assert.Equal(t, int32(0), msg.Partition)
+	}
+}
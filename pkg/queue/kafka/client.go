@@ -0,0 +1,25 @@
+package kafka
+
+import (
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+)
+
+var _ ConsumerClientInterface = (*kafka.Consumer)(nil)
+
+// ConsumerClientInterface is the part of kafka.Consumer used by Consumer, so tests
+// can run it against an in-memory broker.
+type ConsumerClientInterface interface {
+	SubscribeTopics(topics []string, rebalanceCb kafka.RebalanceCb) error
+	ReadMessage(timeout time.Duration) (*kafka.Message, error)
+	CommitMessage(msg *kafka.Message) ([]kafka.TopicPartition, error)
+	CommitOffsets(offsets []kafka.TopicPartition) ([]kafka.TopicPartition, error)
+	Assignment() ([]kafka.TopicPartition, error)
+	Committed(partitions []kafka.TopicPartition, timeoutMs int) ([]kafka.TopicPartition, error)
+	QueryWatermarkOffsets(topic string, partition int32, timeoutMs int) (low, high int64, err error)
+	Pause(partitions []kafka.TopicPartition) error
+	Resume(partitions []kafka.TopicPartition) error
+	Seek(partition kafka.TopicPartition, timeoutMs int) error
+	Close() error
+}
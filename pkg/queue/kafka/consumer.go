@@ -9,12 +9,14 @@ import (
 	logger "go-source/pkg/log"
 	"go-source/pkg/utils"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
 var (
-	ErrAlreadyStarted  = errors.New("already started")
-	ErrNilEventHandler = errors.New("event handlers is nil")
+	ErrAlreadyStarted   = errors.New("already started")
+	ErrNilEventHandler  = errors.New("event handlers is nil")
+	ErrNilRetryProducer = errors.New("retry producer is nil")
 )
 
 type OnEventHandler func(ctx context.Context, key, value []byte) error
@@ -25,22 +27,68 @@ type ConsumerInterface interface {
 	Shutdown(ctx context.Context)
 }
 
+const pollTimeout = time.Second
+
 type Consumer struct {
-	cs      *kafka.Consumer
+	cs      ConsumerClientInterface
 	topics  []string
 	handler OnEventHandler
 
-	started bool
-	mu      sync.RWMutex
+	retry         *RetryPolicy
+	retryProducer ProducerInterface
+	paused        []pausedPartition
+
+	workers     int
+	maxInFlight int
+	orderBy     OrderBy
+	queues      []chan *kafka.Message
+	inFlight    chan struct{}
+	tracker     *offsetTracker
+	wg          sync.WaitGroup
+
+	dedup ProcessedStore
+
+	batchHandler OnBatchHandler
+	batchSize    int
+	batchWait    time.Duration
+	// batch is the batch being collected, only used on the poll goroutine
+	batch []*kafka.Message
+
+	valueLogMode      ValueLogMode
+	valueLogMaxLength int
+	lagInterval       time.Duration
+	lagThreshold      int64
+	maxLag            atomic.Int64
+
+	rebalanceListener *RebalanceListener
+	revokeTimeout     time.Duration
+
+	started   bool
+	cancel    context.CancelFunc
+	done      chan struct{}
+	closeOnce sync.Once
+	mu        sync.RWMutex
 }
 
-func NewConsumer(cfg KafkaConfig, topics []string) *Consumer {
+type ConsumerOption func(s *Consumer)
+
+// WithRetryPolicy enables retry topics and the DLQ, published through producer.
+// The consumer also subscribes to the retry topics of its topics.
+func WithRetryPolicy(policy RetryPolicy, producer ProducerInterface) ConsumerOption {
+	return func(s *Consumer) {
+		s.retry = &policy
+		s.retryProducer = producer
+	}
+}
+
+func NewConsumer(cfg KafkaConfig, topics []string, opts ...ConsumerOption) *Consumer {
 	log := logger.GetLogger()
 
 	cfgMap := kafka.ConfigMap{
-		"bootstrap.servers": cfg.BootstrapServers,
-		"group.id":          cfg.GroupID,
-		"auto.offset.reset": cfg.AutoOffsetReset,
+		"bootstrap.servers":  cfg.BootstrapServers,
+		"group.id":           cfg.GroupID,
+		"auto.offset.reset":  cfg.AutoOffsetReset,
+		"enable.auto.commit": false,
 	}
 
 	if cfg.SecurityProtocol != "" {
@@ -67,11 +115,26 @@ func NewConsumer(cfg KafkaConfig, topics []string) *Consumer {
 		log.Fatal().Err(err).Msg("init kafka consumer failed")
 	}
 
+	res := NewConsumerWithClient(cs, topics, opts...)
+
 	log.Info().Msgf("init kafka consumer success : TOPIC = %v", topics)
-	return &Consumer{
-		cs:     cs,
-		topics: topics,
+	return res
+}
+
+// NewConsumerWithClient creates a consumer reading through client, e.g. the client
+// of an in-memory broker in tests.
+func NewConsumerWithClient(client ConsumerClientInterface, topics []string, opts ...ConsumerOption) *Consumer {
+	res := &Consumer{
+		cs:            client,
+		topics:        topics,
+		revokeTimeout: defaultRevokeTimeout,
+	}
+
+	for _, opt := range opts {
+		opt(res)
 	}
+
+	return res
 }
 
 func (s *Consumer) OnEvent(handler OnEventHandler) {
@@ -88,20 +151,59 @@ func (s *Consumer) Start(ctx context.Context) error {
 		s.mu.Unlock()
 		return ErrAlreadyStarted
 	}
-	if s.handler == nil {
+	if s.handler == nil && s.batchHandler == nil {
 		s.mu.Unlock()
 		return ErrNilEventHandler
 	}
+	if s.retry != nil && s.retryProducer == nil {
+		s.mu.Unlock()
+		return ErrNilRetryProducer
+	}
+	ctx, s.cancel = context.WithCancel(ctx)
+	s.done = make(chan struct{})
 	s.started = true
 	s.mu.Unlock()
 
-	if err := s.cs.SubscribeTopics(s.topics, nil); err != nil {
+	defer close(s.done)
+
+	topics := append([]string{}, s.topics...)
+	if s.retry != nil {
+		topics = append(topics, s.retry.retryTopics(s.topics)...)
+	}
+
+	if err := s.cs.SubscribeTopics(topics, s.rebalance); err != nil {
 		return fmt.Errorf("subscribe: %w", err)
 	}
 
+	if s.lagInterval > 0 {
+		lagDone := make(chan struct{})
+		go func() {
+			defer close(lagDone)
+			s.monitorLag(ctx)
+		}()
+		defer func() { <-lagDone }()
+	}
+
+	if s.batchHandler != nil {
+		return s.consumeBatches(ctx)
+	}
+
+	if s.workers > 1 {
+		s.startWorkers(ctx)
+		defer s.stopWorkers()
+	}
+
 	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		default:
+		}
+
 		log := logger.GetLogger()
-		msg, err := s.cs.ReadMessage(10 * time.Second)
+		s.resumeDue()
+
+		msg, err := s.cs.ReadMessage(pollTimeout)
 		if err != nil {
 			var kerr kafka.Error
 			if errors.As(err, &kerr); kerr.Code() == kafka.ErrTimedOut {
@@ -111,37 +213,19 @@ func (s *Consumer) Start(ctx context.Context) error {
 			continue
 		}
 
-		traceInfoExisted := false
-		newCtx := context.Background()
-
-		for _, h := range msg.Headers {
-			if h.Key == utils.KeyTraceInfo {
-				traceInfo := utils.TraceInfo{}
-				if err = json.Unmarshal(h.Value, &traceInfo); err != nil {
-					break
-				}
-				newCtx = context.WithValue(newCtx, utils.KeyTraceInfo, traceInfo)
-				traceInfoExisted = true
-			}
+		if s.retry != nil && s.delayIfNotDue(msg) {
+			continue
 		}
 
-		if !traceInfoExisted {
-			newCtx, _ = utils.NewContextWithRequestId(ctx)
+		if s.workers > 1 {
+			if err = s.dispatch(ctx, msg); err != nil {
+				// cancelled while waiting for an in-flight slot, the message was not dispatched
+				return nil
+			}
+			continue
 		}
 
-		log = log.AddTraceInfoContextRequest(newCtx)
-		log.Info().
-			Interface("topicPartition", msg.TopicPartition).
-			Str("value", string(msg.Value)).
-			Str("key", string(msg.Key)).
-			Time("timestamp", msg.Timestamp).
-			Int("timestampType", int(msg.TimestampType)).
-			Interface("opaque", msg.Opaque).
-			Interface("headers", msg.Headers).
-			Msg("kafka read message success")
-
-		if err = s.handler(newCtx, msg.Key, msg.Value); err != nil {
-			log.Err(err).Msg("kafka handlers failed")
+		if !s.process(ctx, msg) {
 			continue
 		}
 
@@ -151,7 +235,112 @@ func (s *Consumer) Start(ctx context.Context) error {
 	}
 }
 
+// process handles one message and reports whether it is done and can be committed.
+func (s *Consumer) process(ctx context.Context, msg *kafka.Message) bool {
+	log := logger.GetLogger()
+	newCtx := messageContext(ctx, msg)
+
+	log = log.AddTraceInfoContextRequest(newCtx)
+	event := log.Info().Interface("topicPartition", msg.TopicPartition)
+	if value, ok := s.logValue(msg.Value); ok {
+		event = event.Str("value", value)
+	}
+	event.
+		Str("key", string(msg.Key)).
+		Time("timestamp", msg.Timestamp).
+		Int("timestampType", int(msg.TimestampType)).
+		Interface("opaque", msg.Opaque).
+		Interface("headers", msg.Headers).
+		Msg("kafka read message success")
+
+	attempts, err := s.handleWithRetry(newCtx, msg)
+	if err == nil {
+		return true
+	}
+
+	log.Err(err).Msg("kafka handlers failed")
+	if s.retry == nil {
+		return false
+	}
+
+	return s.routeUntilDone(ctx, newCtx, msg, attempts, err)
+}
+
+// messageContext builds the handler context, carrying the trace info of the message headers.
+func messageContext(ctx context.Context, msg *kafka.Message) context.Context {
+	traceInfoExisted := false
+	newCtx := context.Background()
+
+	for _, h := range msg.Headers {
+		if h.Key == utils.KeyTraceInfo {
+			traceInfo := utils.TraceInfo{}
+			if err := json.Unmarshal(h.Value, &traceInfo); err != nil {
+				break
+			}
+			newCtx = context.WithValue(newCtx, utils.KeyTraceInfo, traceInfo)
+			traceInfoExisted = true
+		}
+	}
+
+	if !traceInfoExisted {
+		// in-flight handlers are drained on shutdown, do not hand them a cancelled context
+		newCtx, _ = utils.NewContextWithRequestId(context.WithoutCancel(ctx))
+	}
+
+	return ContextWithTopic(newCtx, *msg.TopicPartition.Topic)
+}
+
+type topicKey struct{}
+
+// ContextWithTopic sets the topic of the message being handled, for consumers of
+// other brokers calling an OnEventHandler.
+func ContextWithTopic(ctx context.Context, topic string) context.Context {
+	return context.WithValue(ctx, topicKey{}, topic)
+}
+
+// TopicFromContext returns the topic of the message being handled, empty when the
+// consumer didn't set it.
+func TopicFromContext(ctx context.Context) string {
+	topic, _ := ctx.Value(topicKey{}).(string)
+	return topic
+}
+
+// Shutdown stops reading, waits for in-flight handlers until ctx is done, commits
+// the processed offsets and closes the consumer. When ctx expires first, the
+// consumer is closed in the background once Start returns.
 func (s *Consumer) Shutdown(ctx context.Context) {
+	log := logger.GetLogger()
+
+	s.mu.RLock()
+	cancel, done := s.cancel, s.done
+	s.mu.RUnlock()
+
+	if cancel == nil {
+		s.close()
+		return
+	}
+	cancel()
+
+	select {
+	case <-done:
+		s.close()
+	case <-ctx.Done():
+		log.Warn().Msg("kafka consumer shutdown timeout, in-flight messages will be redelivered")
+		go func() {
+			<-done
+			s.close()
+		}()
+	}
+}
+
+func (s *Consumer) close() {
+	s.closeOnce.Do(func() {
+		if err := s.cs.Close(); err != nil {
+			logger.GetLogger().Err(err).Msg("kafka consumer close failed")
+			return
+		}
+		logger.GetLogger().Info().Msgf("kafka consumer closed : TOPIC = %v", s.topics)
+	})
 }
 
 func (s *Consumer) GetTopics() []string {
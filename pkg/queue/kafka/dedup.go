@@ -0,0 +1,99 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	logger "go-source/pkg/log"
+	"go-source/pkg/utils"
+	"strconv"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+)
+
+const HeaderMessageID = "x-message-id"
+
+// ErrAlreadyProcessed is returned by a handler, or a ProcessedStore transaction, that
+// found out the message was processed concurrently. The message is then committed.
+var ErrAlreadyProcessed = errors.New("message already processed")
+
+// ProcessedStore remembers the messages already processed, so redelivered messages
+// are not handled twice.
+type ProcessedStore interface {
+	IsProcessed(ctx context.Context, id string) (bool, error)
+	MarkProcessed(ctx context.Context, id string) error
+}
+
+type messageIDKey struct{}
+
+// WithDedup skips the messages store has already processed, and marks the handled
+// ones as processed.
+func WithDedup(store ProcessedStore) ConsumerOption {
+	return func(s *Consumer) {
+		s.dedup = store
+	}
+}
+
+// MessageID returns the x-message-id header, or else a hash of the key, partition and
+// offset of the message. A retried message keeps the partition and offset of the
+// original message, so it keeps its ID.
+func MessageID(msg *kafka.Message) string {
+	if id := headerValue(msg.Headers, HeaderMessageID); id != "" {
+		return id
+	}
+
+	topic := headerValue(msg.Headers, HeaderOriginalTopic)
+	partition := headerValue(msg.Headers, HeaderOriginalPartition)
+	offset := headerValue(msg.Headers, HeaderOriginalOffset)
+	if topic == "" {
+		topic = *msg.TopicPartition.Topic
+		partition = strconv.Itoa(int(msg.TopicPartition.Partition))
+		offset = msg.TopicPartition.Offset.String()
+	}
+
+	return utils.Sha256HashHex([]byte(fmt.Sprintf("%s|%s|%s|%s", topic, partition, offset, msg.Key)))
+}
+
+// MessageIDFromContext returns the ID of the message being handled, when dedup is enabled.
+func MessageIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(messageIDKey{}).(string)
+	return id
+}
+
+func contextWithMessageID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, messageIDKey{}, id)
+}
+
+// handle runs the handler unless the message was already processed.
+func (s *Consumer) handle(ctx context.Context, msg *kafka.Message) error {
+	if s.dedup == nil {
+		return s.callHandler(ctx, msg)
+	}
+
+	id := MessageID(msg)
+	ctx = contextWithMessageID(ctx, id)
+	log := logger.GetLogger().AddTraceInfoContextRequest(ctx)
+
+	processed, err := s.dedup.IsProcessed(ctx, id)
+	if err != nil {
+		return fmt.Errorf("check processed message: %w", err)
+	}
+	if processed {
+		log.Info().Msgf("kafka message already processed, skipped: id=%s", id)
+		return nil
+	}
+
+	if err = s.callHandler(ctx, msg); err != nil {
+		if errors.Is(err, ErrAlreadyProcessed) {
+			log.Info().Msgf("kafka message already processed, skipped: id=%s", id)
+			return nil
+		}
+		return err
+	}
+
+	// running the handler again would be worse than a missing mark
+	if err = s.dedup.MarkProcessed(ctx, id); err != nil {
+		log.Warn().Err(err).Msgf("kafka mark message processed failed: id=%s", id)
+	}
+	return nil
+}
@@ -0,0 +1,159 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	logger "go-source/pkg/log"
+	"go-source/pkg/metric"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+)
+
+const flushInterval = 100 * time.Millisecond
+
+var ErrProducerClosed = errors.New("producer is closed")
+
+// DeliveryCallback is called with the delivery report of a message, err is nil
+// when the message was written to the broker.
+type DeliveryCallback func(msg *kafka.Message, err error)
+
+// OnDelivery sets a callback called with the delivery report of every message
+// published asynchronously, e.g. by Publish.
+func (s *Producer) OnDelivery(cb DeliveryCallback) {
+	s.mu.Lock()
+	s.onDelivery = cb
+	s.mu.Unlock()
+}
+
+// PublishAsync publishes without waiting, cb is called with the delivery report.
+func (s *Producer) PublishAsync(ctx context.Context, key, value interface{}, cb DeliveryCallback) error {
+	msg, err := s.newMessage(ctx, s.topic, key, value)
+	if err != nil {
+		return err
+	}
+
+	msg.Opaque = cb
+	msg.TopicPartition.Partition = s.partition(s.topic, msg.Key)
+	return s.produce(msg, nil)
+}
+
+// PublishSync publishes and waits for the delivery report of the message.
+func (s *Producer) PublishSync(ctx context.Context, key, value interface{}) error {
+	msg, err := s.newMessage(ctx, s.topic, key, value)
+	if err != nil {
+		return err
+	}
+	msg.TopicPartition.Partition = s.partition(s.topic, msg.Key)
+	return s.PublishMessageSync(ctx, msg)
+}
+
+// PublishMessageSync publishes msg and waits for its delivery report.
+func (s *Producer) PublishMessageSync(ctx context.Context, msg *kafka.Message) error {
+	deliveryChan := make(chan kafka.Event, 1)
+	if err := s.produce(msg, deliveryChan); err != nil {
+		return err
+	}
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case e := <-deliveryChan:
+		report, ok := e.(*kafka.Message)
+		if !ok {
+			return fmt.Errorf("kafka unexpected delivery event: %v", e)
+		}
+		if err := report.TopicPartition.Error; err != nil {
+			recordDeliveryError(report, err)
+			return err
+		}
+		return nil
+	}
+}
+
+// Close flushes the outstanding messages, until ctx is done, then closes the producer.
+func (s *Producer) Close(ctx context.Context) error {
+	s.closeMu.Lock()
+	if s.closed {
+		s.closeMu.Unlock()
+		return nil
+	}
+	s.closed = true
+	s.closeMu.Unlock()
+
+	remaining := s.pr.Len()
+	for remaining > 0 && ctx.Err() == nil {
+		remaining = s.pr.Flush(int(flushInterval.Milliseconds()))
+	}
+
+	s.pr.Close()
+	<-s.eventsDone
+
+	if remaining > 0 {
+		return fmt.Errorf("kafka producer closed with %d messages not delivered", remaining)
+	}
+
+	logger.GetLogger().Info().Msgf("kafka producer closed : TOPIC = %v", s.topic)
+	return nil
+}
+
+func (s *Producer) produce(msg *kafka.Message, deliveryChan chan kafka.Event) error {
+	s.closeMu.RLock()
+	defer s.closeMu.RUnlock()
+
+	if s.closed {
+		return ErrProducerClosed
+	}
+	return s.pr.Produce(msg, deliveryChan)
+}
+
+// eventsLoop reads the delivery reports of messages published without delivery channel,
+// it ends when the producer is closed.
+func (s *Producer) eventsLoop() {
+	defer close(s.eventsDone)
+
+	log := logger.GetLogger()
+	for e := range s.pr.Events() {
+		switch ev := e.(type) {
+		case *kafka.Message:
+			err := ev.TopicPartition.Error
+			if err != nil {
+				recordDeliveryError(ev, err)
+			}
+
+			if cb, ok := ev.Opaque.(DeliveryCallback); ok && cb != nil {
+				cb(ev, err)
+			}
+
+			s.mu.RLock()
+			onDelivery := s.onDelivery
+			s.mu.RUnlock()
+			if onDelivery != nil {
+				onDelivery(ev, err)
+			}
+		case kafka.Error:
+			log.Err(ev).Msgf("kafka producer error : TOPIC = %v", s.topic)
+			metric.NewKafkaDeliveryErrorCounter(s.topic, ev.Code().String())
+		}
+	}
+}
+
+func recordDeliveryError(msg *kafka.Message, err error) {
+	var topic string
+	if msg.TopicPartition.Topic != nil {
+		topic = *msg.TopicPartition.Topic
+	}
+
+	code := metric.DefaultErr.Error()
+	var kerr kafka.Error
+	if errors.As(err, &kerr) {
+		code = kerr.Code().String()
+	}
+
+	logger.GetLogger().Err(err).
+		Str("topic", topic).
+		Bytes("key", msg.Key).
+		Msg("kafka message delivery failed")
+	metric.NewKafkaDeliveryErrorCounter(topic, code)
+}
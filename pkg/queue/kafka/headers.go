@@ -0,0 +1,46 @@
+package kafka
+
+import (
+	"context"
+	"sort"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+)
+
+type headersKey struct{}
+
+// ContextWithHeaders adds headers to the messages published with the returned context.
+func ContextWithHeaders(ctx context.Context, headers map[string]string) context.Context {
+	merged := make(map[string]string, len(headers))
+	for k, v := range HeadersFromContext(ctx) {
+		merged[k] = v
+	}
+	for k, v := range headers {
+		merged[k] = v
+	}
+	return context.WithValue(ctx, headersKey{}, merged)
+}
+
+func HeadersFromContext(ctx context.Context) map[string]string {
+	headers, _ := ctx.Value(headersKey{}).(map[string]string)
+	return headers
+}
+
+func contextHeaders(ctx context.Context) []kafka.Header {
+	headers := HeadersFromContext(ctx)
+	if len(headers) == 0 {
+		return nil
+	}
+
+	keys := make([]string, 0, len(headers))
+	for k := range headers {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	res := make([]kafka.Header, 0, len(keys))
+	for _, k := range keys {
+		res = append(res, kafka.Header{Key: k, Value: []byte(headers[k])})
+	}
+	return res
+}
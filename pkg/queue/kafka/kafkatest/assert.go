@@ -0,0 +1,56 @@
+package kafkatest
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+// WaitConsumed waits until group committed every message of topic, and fails t
+// after timeout.
+func (b *Broker) WaitConsumed(t testing.TB, groupID, topic string, timeout time.Duration) {
+	t.Helper()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	for {
+		changed := b.wait()
+		if b.Lag(groupID, topic) == 0 {
+			return
+		}
+
+		select {
+		case <-changed:
+		case <-timer.C:
+			t.Fatalf("kafkatest: group %s did not consume topic %s in %s: lag=%d", groupID, topic, timeout, b.Lag(groupID, topic))
+			return
+		}
+	}
+}
+
+// AssertPublished fails t unless topic holds n messages, and returns them.
+func (b *Broker) AssertPublished(t testing.TB, topic string, n int) []Message {
+	t.Helper()
+
+	msgs := b.Messages(topic)
+	if len(msgs) != n {
+		t.Errorf("kafkatest: topic %s has %d messages, expected %d", topic, len(msgs), n)
+	}
+	return msgs
+}
+
+// AssertHeader fails t unless msg has the header key with value.
+func AssertHeader(t testing.TB, msg Message, key, value string) {
+	t.Helper()
+
+	for _, h := range msg.Headers {
+		if h.Key == key {
+			if !bytes.Equal(h.Value, []byte(value)) {
+				t.Errorf("kafkatest: header %s is %q, expected %q", key, h.Value, value)
+			}
+			return
+		}
+	}
+	t.Errorf("kafkatest: header %s not found", key)
+}
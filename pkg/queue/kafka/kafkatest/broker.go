@@ -0,0 +1,255 @@
+// Package kafkatest provides an in-memory broker implementing the producer and
+// consumer interfaces of the kafka package, for tests without a Kafka cluster.
+package kafkatest
+
+import (
+	"hash/fnv"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+)
+
+const defaultPartitions = 1
+
+// Message is a message stored by the broker.
+type Message struct {
+	Topic     string
+	Partition int32
+	Offset    int64
+	Key       []byte
+	Value     []byte
+	Headers   []kafka.Header
+	Timestamp time.Time
+}
+
+type topicPartition struct {
+	topic     string
+	partition int32
+}
+
+type group struct {
+	committed map[topicPartition]int64
+	// members are *Consumer and *Client
+	members []any
+	// generation changes on every rebalance, members then reset their positions
+	generation int
+}
+
+// Broker keeps topics, partitions and consumer group offsets in memory.
+// Topics are created with the default number of partitions when first used.
+type Broker struct {
+	partitions int
+	topics     map[string][][]Message
+	groups     map[string]*group
+	// notify is closed and replaced whenever a message is published or the groups change
+	notify chan struct{}
+	mu     sync.Mutex
+}
+
+type BrokerOption func(b *Broker)
+
+// WithPartitions sets the number of partitions of the topics created on first use.
+func WithPartitions(partitions int) BrokerOption {
+	return func(b *Broker) {
+		b.partitions = partitions
+	}
+}
+
+func NewBroker(opts ...BrokerOption) *Broker {
+	b := &Broker{
+		partitions: defaultPartitions,
+		topics:     make(map[string][][]Message),
+		groups:     make(map[string]*group),
+		notify:     make(chan struct{}),
+	}
+
+	for _, opt := range opts {
+		opt(b)
+	}
+
+	return b
+}
+
+// CreateTopic creates topic with partitions, it does nothing when topic exists.
+func (b *Broker) CreateTopic(topic string, partitions int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.topics[topic]; !ok {
+		b.topics[topic] = make([][]Message, partitions)
+	}
+}
+
+func (b *Broker) topic(name string) [][]Message {
+	partitions, ok := b.topics[name]
+	if !ok {
+		partitions = make([][]Message, b.partitions)
+		b.topics[name] = partitions
+	}
+	return partitions
+}
+
+// produce appends msg to its partition, or to the partition of its key when it is
+// negative, and returns the stored message.
+func (b *Broker) produce(msg Message) Message {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	partitions := b.topic(msg.Topic)
+	if msg.Partition < 0 || int(msg.Partition) >= len(partitions) {
+		msg.Partition = partitionOf(msg.Key, len(partitions))
+	}
+
+	msg.Offset = int64(len(partitions[msg.Partition]))
+	if msg.Timestamp.IsZero() {
+		msg.Timestamp = time.Now()
+	}
+	partitions[msg.Partition] = append(partitions[msg.Partition], msg)
+
+	b.broadcast()
+	return msg
+}
+
+// partitionCount returns the number of partitions of topic, creating it when needed.
+func (b *Broker) partitionCount(topic string) int32 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return int32(len(b.topic(topic)))
+}
+
+func (m Message) kafkaMessage() *kafka.Message {
+	topic := m.Topic
+	return &kafka.Message{
+		TopicPartition: kafka.TopicPartition{Topic: &topic, Partition: m.Partition, Offset: kafka.Offset(m.Offset)},
+		Key:            m.Key,
+		Value:          m.Value,
+		Headers:        m.Headers,
+		Timestamp:      m.Timestamp,
+	}
+}
+
+func partitionOf(key []byte, partitions int) int32 {
+	h := fnv.New32a()
+	_, _ = h.Write(key)
+	return int32(h.Sum32() % uint32(partitions))
+}
+
+func (b *Broker) broadcast() {
+	close(b.notify)
+	b.notify = make(chan struct{})
+}
+
+func (b *Broker) group(id string) *group {
+	g, ok := b.groups[id]
+	if !ok {
+		g = &group{committed: make(map[topicPartition]int64)}
+		b.groups[id] = g
+	}
+	return g
+}
+
+func (b *Broker) join(groupID string, c any) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	g := b.group(groupID)
+	g.members = append(g.members, c)
+	g.generation++
+	b.broadcast()
+}
+
+func (b *Broker) leave(groupID string, c any) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	g := b.group(groupID)
+	if i := slices.Index(g.members, c); i >= 0 {
+		g.members = slices.Delete(g.members, i, i+1)
+		g.generation++
+		b.broadcast()
+	}
+}
+
+// assignment returns the partitions of topics assigned to c, spread round robin
+// over the members of the group.
+func (b *Broker) assignment(g *group, c any, topics []string) []topicPartition {
+	var all []topicPartition
+	for _, topic := range topics {
+		for p := range b.topic(topic) {
+			all = append(all, topicPartition{topic: topic, partition: int32(p)})
+		}
+	}
+	sort.Slice(all, func(i, j int) bool {
+		if all[i].topic != all[j].topic {
+			return all[i].topic < all[j].topic
+		}
+		return all[i].partition < all[j].partition
+	})
+
+	index := slices.Index(g.members, c)
+	if index < 0 {
+		return nil
+	}
+
+	var res []topicPartition
+	for i, tp := range all {
+		if i%len(g.members) == index {
+			res = append(res, tp)
+		}
+	}
+	return res
+}
+
+func (b *Broker) commit(groupID string, tp topicPartition, offset int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	g := b.group(groupID)
+	if offset > g.committed[tp] {
+		g.committed[tp] = offset
+	}
+	b.broadcast()
+}
+
+// Messages returns the messages of all partitions of topic.
+func (b *Broker) Messages(topic string) []Message {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var res []Message
+	for _, partition := range b.topics[topic] {
+		res = append(res, partition...)
+	}
+	return res
+}
+
+// Committed returns the offset committed by group for the partition, 0 when none.
+func (b *Broker) Committed(groupID, topic string, partition int32) int64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.group(groupID).committed[topicPartition{topic: topic, partition: partition}]
+}
+
+// Lag returns the number of messages of topic not committed by group yet.
+func (b *Broker) Lag(groupID, topic string) int64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	g := b.group(groupID)
+	var lag int64
+	for p, partition := range b.topics[topic] {
+		lag += int64(len(partition)) - g.committed[topicPartition{topic: topic, partition: int32(p)}]
+	}
+	return lag
+}
+
+// wait returns a channel closed on the next change of the broker.
+func (b *Broker) wait() <-chan struct{} {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.notify
+}
@@ -0,0 +1,226 @@
+package kafkatest
+
+import (
+	"go-source/pkg/queue/kafka"
+	"sync"
+	"time"
+
+	confluent "github.com/confluentinc/confluent-kafka-go/kafka"
+)
+
+var _ kafka.ConsumerClientInterface = (*Client)(nil)
+
+// Client is a consumer group member standing in for confluent's consumer, to run a
+// kafka.Consumer built with kafka.NewConsumerWithClient against the broker. Like the
+// default eager protocol, a rebalance revokes the whole assignment before assigning
+// the new one, the rebalance callback runs inside ReadMessage.
+type Client struct {
+	broker      *Broker
+	groupID     string
+	topics      []string
+	rebalanceCb confluent.RebalanceCb
+
+	assigned   []topicPartition
+	positions  map[topicPartition]int64
+	paused     map[topicPartition]bool
+	generation int
+	next       int
+	mu         sync.Mutex
+}
+
+func (b *Broker) NewClient(groupID string) *Client {
+	return &Client{
+		broker:    b,
+		groupID:   groupID,
+		positions: make(map[topicPartition]int64),
+		paused:    make(map[topicPartition]bool),
+	}
+}
+
+// SubscribeTopics joins the group, the partitions are assigned on the next ReadMessage.
+func (s *Client) SubscribeTopics(topics []string, rebalanceCb confluent.RebalanceCb) error {
+	s.mu.Lock()
+	s.topics = topics
+	s.rebalanceCb = rebalanceCb
+	s.mu.Unlock()
+
+	s.broker.join(s.groupID, s)
+	return nil
+}
+
+func (s *Client) ReadMessage(timeout time.Duration) (*confluent.Message, error) {
+	deadline := time.After(timeout)
+	for {
+		// taken before fetching, so a message published meanwhile is not missed
+		changed := s.broker.wait()
+
+		s.rebalance()
+		if msg, ok := s.fetch(); ok {
+			return msg.kafkaMessage(), nil
+		}
+
+		select {
+		case <-changed:
+		case <-deadline:
+			return nil, confluent.NewError(confluent.ErrTimedOut, "timed out", false)
+		}
+	}
+}
+
+// rebalance revokes then assigns the partitions when the group changed since the last call.
+func (s *Client) rebalance() {
+	s.mu.Lock()
+	topics, revoked, seen := s.topics, s.assigned, s.generation
+	s.mu.Unlock()
+
+	b := s.broker
+	b.mu.Lock()
+	g := b.group(s.groupID)
+	if seen == g.generation {
+		b.mu.Unlock()
+		return
+	}
+	assignment := b.assignment(g, s, topics)
+	generation := g.generation
+	b.mu.Unlock()
+
+	// the callback may call the client back, it runs unlocked
+	if len(revoked) > 0 && s.rebalanceCb != nil {
+		_ = s.rebalanceCb(nil, confluent.RevokedPartitions{Partitions: kafkaPartitions(revoked)})
+	}
+
+	s.mu.Lock()
+	s.assigned = assignment
+	s.generation = generation
+	s.positions = make(map[topicPartition]int64)
+	s.paused = make(map[topicPartition]bool)
+	s.next = 0
+	s.mu.Unlock()
+
+	if len(assignment) > 0 && s.rebalanceCb != nil {
+		_ = s.rebalanceCb(nil, confluent.AssignedPartitions{Partitions: kafkaPartitions(assignment)})
+	}
+}
+
+// fetch returns the next message of the assigned partitions not paused, taking them in turn.
+func (s *Client) fetch() (Message, bool) {
+	b := s.broker
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	g := b.group(s.groupID)
+	for i := range s.assigned {
+		tp := s.assigned[(s.next+i)%len(s.assigned)]
+		if s.paused[tp] {
+			continue
+		}
+
+		position, ok := s.positions[tp]
+		if !ok {
+			position = g.committed[tp]
+		}
+
+		partition := b.topics[tp.topic][tp.partition]
+		if position < int64(len(partition)) {
+			s.positions[tp] = position + 1
+			s.next = (s.next + i + 1) % len(s.assigned)
+			return partition[position], true
+		}
+	}
+
+	return Message{}, false
+}
+
+func (s *Client) CommitMessage(msg *confluent.Message) ([]confluent.TopicPartition, error) {
+	tp := msg.TopicPartition
+	tp.Offset++
+	return s.CommitOffsets([]confluent.TopicPartition{tp})
+}
+
+func (s *Client) CommitOffsets(offsets []confluent.TopicPartition) ([]confluent.TopicPartition, error) {
+	for _, tp := range offsets {
+		s.broker.commit(s.groupID, topicPartition{topic: *tp.Topic, partition: tp.Partition}, int64(tp.Offset))
+	}
+	return offsets, nil
+}
+
+func (s *Client) Assignment() ([]confluent.TopicPartition, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return kafkaPartitions(s.assigned), nil
+}
+
+// Committed returns the committed offsets, confluent.OffsetInvalid when none.
+func (s *Client) Committed(partitions []confluent.TopicPartition, _ int) ([]confluent.TopicPartition, error) {
+	b := s.broker
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	g := b.group(s.groupID)
+	res := make([]confluent.TopicPartition, len(partitions))
+	for i, tp := range partitions {
+		res[i] = tp
+		res[i].Offset = confluent.OffsetInvalid
+		if offset, ok := g.committed[topicPartition{topic: *tp.Topic, partition: tp.Partition}]; ok {
+			res[i].Offset = confluent.Offset(offset)
+		}
+	}
+	return res, nil
+}
+
+func (s *Client) QueryWatermarkOffsets(topic string, partition int32, _ int) (low, high int64, err error) {
+	b := s.broker
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	partitions := b.topic(topic)
+	if int(partition) >= len(partitions) {
+		return 0, 0, confluent.NewError(confluent.ErrUnknownPartition, "unknown partition", false)
+	}
+	return 0, int64(len(partitions[partition])), nil
+}
+
+func (s *Client) Pause(partitions []confluent.TopicPartition) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, tp := range partitions {
+		s.paused[topicPartition{topic: *tp.Topic, partition: tp.Partition}] = true
+	}
+	return nil
+}
+
+func (s *Client) Resume(partitions []confluent.TopicPartition) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, tp := range partitions {
+		delete(s.paused, topicPartition{topic: *tp.Topic, partition: tp.Partition})
+	}
+	return nil
+}
+
+func (s *Client) Seek(partition confluent.TopicPartition, _ int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.positions[topicPartition{topic: *partition.Topic, partition: partition.Partition}] = int64(partition.Offset)
+	return nil
+}
+
+// Close leaves the group, its partitions go to the other members.
+func (s *Client) Close() error {
+	s.broker.leave(s.groupID, s)
+	return nil
+}
+
+func kafkaPartitions(partitions []topicPartition) []confluent.TopicPartition {
+	res := make([]confluent.TopicPartition, len(partitions))
+	for i, tp := range partitions {
+		topic := tp.topic
+		res[i] = confluent.TopicPartition{Topic: &topic, Partition: tp.partition}
+	}
+	return res
+}
@@ -0,0 +1,156 @@
+package kafkatest
+
+import (
+	"context"
+	"encoding/json"
+	"go-source/pkg/queue/kafka"
+	"go-source/pkg/utils"
+	"sync"
+)
+
+var _ kafka.ConsumerInterface = (*Consumer)(nil)
+
+// Consumer reads the topics as a member of a consumer group and commits each
+// message after its handler. Like kafka.Consumer, a failed message is not
+// committed but the next commit of the partition moves past it.
+type Consumer struct {
+	broker  *Broker
+	groupID string
+	topics  []string
+	handler kafka.OnEventHandler
+
+	positions  map[topicPartition]int64
+	generation int
+	next       int
+
+	started bool
+	cancel  context.CancelFunc
+	done    chan struct{}
+	mu      sync.Mutex
+}
+
+func (b *Broker) NewConsumer(groupID string, topics []string) *Consumer {
+	return &Consumer{
+		broker:  b,
+		groupID: groupID,
+		topics:  topics,
+	}
+}
+
+func (s *Consumer) OnEvent(handler kafka.OnEventHandler) {
+	s.mu.Lock()
+	if handler != nil {
+		s.handler = handler
+	}
+	s.mu.Unlock()
+}
+
+func (s *Consumer) Start(ctx context.Context) error {
+	s.mu.Lock()
+	if s.started {
+		s.mu.Unlock()
+		return kafka.ErrAlreadyStarted
+	}
+	if s.handler == nil {
+		s.mu.Unlock()
+		return kafka.ErrNilEventHandler
+	}
+	ctx, s.cancel = context.WithCancel(ctx)
+	s.done = make(chan struct{})
+	s.started = true
+	s.mu.Unlock()
+
+	defer close(s.done)
+
+	s.broker.join(s.groupID, s)
+	defer s.broker.leave(s.groupID, s)
+
+	for {
+		// taken before fetching, so a message published meanwhile is not missed
+		changed := s.broker.wait()
+
+		msg, ok := s.fetch()
+		if !ok {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-changed:
+				continue
+			}
+		}
+
+		if err := s.handler(messageContext(ctx, msg), msg.Key, msg.Value); err == nil {
+			s.broker.commit(s.groupID, topicPartition{topic: msg.Topic, partition: msg.Partition}, msg.Offset+1)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		default:
+		}
+	}
+}
+
+// fetch returns the next message of the assigned partitions, taking them in turn.
+func (s *Consumer) fetch() (Message, bool) {
+	b := s.broker
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	g := b.group(s.groupID)
+	if s.positions == nil || s.generation != g.generation {
+		s.positions = make(map[topicPartition]int64)
+		s.generation = g.generation
+	}
+
+	assignment := b.assignment(g, s, s.topics)
+	for i := range assignment {
+		tp := assignment[(s.next+i)%len(assignment)]
+
+		position, ok := s.positions[tp]
+		if !ok {
+			position = g.committed[tp]
+		}
+
+		partition := b.topics[tp.topic][tp.partition]
+		if position < int64(len(partition)) {
+			s.positions[tp] = position + 1
+			s.next = (s.next + i + 1) % len(assignment)
+			return partition[position], true
+		}
+	}
+
+	return Message{}, false
+}
+
+// Shutdown stops reading and waits for the message being handled, until ctx is done.
+func (s *Consumer) Shutdown(ctx context.Context) {
+	s.mu.Lock()
+	cancel, done := s.cancel, s.done
+	s.mu.Unlock()
+
+	if cancel == nil {
+		return
+	}
+	cancel()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+	}
+}
+
+func messageContext(ctx context.Context, msg Message) context.Context {
+	newCtx := kafka.ContextWithTopic(context.WithoutCancel(ctx), msg.Topic)
+	for _, h := range msg.Headers {
+		if h.Key == utils.KeyTraceInfo {
+			traceInfo := utils.TraceInfo{}
+			if err := json.Unmarshal(h.Value, &traceInfo); err == nil {
+				return context.WithValue(newCtx, utils.KeyTraceInfo, traceInfo)
+			}
+		}
+	}
+
+	newCtx, _ = utils.NewContextWithRequestId(newCtx)
+	return newCtx
+}
@@ -0,0 +1,168 @@
+package kafkatest
+
+import (
+	"context"
+	"encoding/json"
+	"go-source/pkg/queue/kafka"
+	"go-source/pkg/utils"
+	"sort"
+
+	confluent "github.com/confluentinc/confluent-kafka-go/kafka"
+)
+
+var _ kafka.ProducerInterface = (*Producer)(nil)
+
+// Producer publishes to the broker the way kafka.Producer does: JSON values and the
+// trace info header. Messages are stored synchronously.
+type Producer struct {
+	broker *Broker
+	topic  string
+}
+
+func (b *Broker) NewProducer(topic string) *Producer {
+	return &Producer{broker: b, topic: topic}
+}
+
+func (s *Producer) Publish(ctx context.Context, key, value interface{}) error {
+	return s.PublishWithTopic(ctx, s.topic, key, value)
+}
+
+func (s *Producer) PublishSync(ctx context.Context, key, value interface{}) error {
+	return s.Publish(ctx, key, value)
+}
+
+func (s *Producer) PublishAsync(ctx context.Context, key, value interface{}, cb kafka.DeliveryCallback) error {
+	msg, err := newMessage(ctx, s.topic, key, value)
+	if err != nil {
+		return err
+	}
+
+	stored := s.broker.produce(msg)
+	if cb != nil {
+		cb(stored.kafkaMessage(), nil)
+	}
+	return nil
+}
+
+func (s *Producer) PublishWithPartitionCRC32(ctx context.Context, key, value interface{}) error {
+	msg, err := newMessage(ctx, s.topic, key, value)
+	if err != nil {
+		return err
+	}
+	msg.Partition = kafka.CRC32Partitioner{}.Partition(msg.Key, s.broker.partitionCount(msg.Topic))
+	s.broker.produce(msg)
+	return nil
+}
+
+func (s *Producer) PublishBytes(ctx context.Context, key, value []byte) error {
+	s.broker.produce(Message{
+		Topic:     s.topic,
+		Partition: confluent.PartitionAny,
+		Key:       key,
+		Value:     value,
+		Headers:   headers(ctx),
+	})
+	return nil
+}
+
+func (s *Producer) PublishWithPartition(ctx context.Context, key, value interface{}, partition int32) error {
+	msg, err := newMessage(ctx, s.topic, key, value)
+	if err != nil {
+		return err
+	}
+	msg.Partition = partition
+	s.broker.produce(msg)
+	return nil
+}
+
+func (s *Producer) PublishWithTopic(ctx context.Context, topic string, key, value interface{}) error {
+	msg, err := newMessage(ctx, topic, key, value)
+	if err != nil {
+		return err
+	}
+	s.broker.produce(msg)
+	return nil
+}
+
+func (s *Producer) PublishMessage(ctx context.Context, msg *confluent.Message) error {
+	topic := s.topic
+	if msg.TopicPartition.Topic != nil {
+		topic = *msg.TopicPartition.Topic
+	}
+
+	s.broker.produce(Message{
+		Topic:     topic,
+		Partition: msg.TopicPartition.Partition,
+		Key:       msg.Key,
+		Value:     msg.Value,
+		Headers:   msg.Headers,
+	})
+	return nil
+}
+
+func (s *Producer) PublishMessageSync(ctx context.Context, msg *confluent.Message) error {
+	return s.PublishMessage(ctx, msg)
+}
+
+func (s *Producer) Close(ctx context.Context) error {
+	return nil
+}
+
+func (s *Producer) GetTopicName() string {
+	return s.topic
+}
+
+func newMessage(ctx context.Context, topic string, key, value interface{}) (Message, error) {
+	keyData, err := marshal(key)
+	if err != nil {
+		return Message{}, err
+	}
+	valueData, err := marshal(value)
+	if err != nil {
+		return Message{}, err
+	}
+
+	var msgHeaders []confluent.Header
+	if traceInfo := utils.GetRequestIdByContext(ctx); traceInfo != nil {
+		header, err := json.Marshal(traceInfo)
+		if err != nil {
+			return Message{}, err
+		}
+		msgHeaders = append(msgHeaders, confluent.Header{Key: utils.KeyTraceInfo, Value: header})
+	}
+
+	return Message{
+		Topic:     topic,
+		Partition: confluent.PartitionAny,
+		Key:       keyData,
+		Value:     valueData,
+		Headers:   append(msgHeaders, headers(ctx)...),
+	}, nil
+}
+
+// headers returns the headers set with kafka.ContextWithHeaders, sorted by key.
+func headers(ctx context.Context) []confluent.Header {
+	values := kafka.HeadersFromContext(ctx)
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var res []confluent.Header
+	for _, k := range keys {
+		res = append(res, confluent.Header{Key: k, Value: []byte(values[k])})
+	}
+	return res
+}
+
+func marshal(val interface{}) ([]byte, error) {
+	switch v := val.(type) {
+	case string:
+		return []byte(v), nil
+	case []byte:
+		return v, nil
+	default:
+		return json.Marshal(val)
+	}
+}
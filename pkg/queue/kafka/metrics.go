@@ -0,0 +1,177 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-source/pkg/client"
+	logger "go-source/pkg/log"
+	"go-source/pkg/metric"
+	"strconv"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+	meter "go.opentelemetry.io/otel/metric"
+)
+
+const (
+	ValueLogFull ValueLogMode = iota
+	ValueLogTruncate
+	ValueLogRedact
+	ValueLogOff
+)
+
+const (
+	codeSuccess      = "SUCCESS"
+	codeNonRetryable = "NON_RETRYABLE"
+	methodHandle     = "handle"
+	methodBatch      = "batch"
+
+	defaultValueLogMaxLength = 1024
+	lagQueryTimeout          = 5 * time.Second
+	truncatedSuffix          = "...(truncated)"
+)
+
+var ErrLagExceeded = errors.New("kafka consumer lag exceeded")
+
+// ValueLogMode chooses how message values are logged when they are read.
+type ValueLogMode int
+
+// WithValueLog sets how values are logged. maxLength bounds the logged value in the
+// truncate and redact modes, redact hides the client.SensitiveData fields first.
+func WithValueLog(mode ValueLogMode, maxLength int) ConsumerOption {
+	return func(s *Consumer) {
+		if maxLength <= 0 {
+			maxLength = defaultValueLogMaxLength
+		}
+		s.valueLogMode = mode
+		s.valueLogMaxLength = maxLength
+	}
+}
+
+// WithLagMonitor exports the lag of the assigned partitions every interval. Above
+// threshold, when positive, HealthCheck reports the consumer unhealthy.
+func WithLagMonitor(interval time.Duration, threshold int64) ConsumerOption {
+	return func(s *Consumer) {
+		s.lagInterval = interval
+		s.lagThreshold = threshold
+	}
+}
+
+func (s *Consumer) logValue(value []byte) (string, bool) {
+	switch s.valueLogMode {
+	case ValueLogOff:
+		return "", false
+	case ValueLogTruncate:
+		return truncate(string(value), s.valueLogMaxLength), true
+	case ValueLogRedact:
+		return truncate(client.SensitiveDataInstance.FilterSensitiveFields(string(value)), s.valueLogMaxLength), true
+	default:
+		return string(value), true
+	}
+}
+
+func truncate(value string, maxLength int) string {
+	if len(value) <= maxLength {
+		return value
+	}
+	return value[:maxLength] + truncatedSuffix
+}
+
+// callHandler runs the handler and records its latency and errors.
+func (s *Consumer) callHandler(ctx context.Context, msg *kafka.Message) error {
+	start := time.Now()
+	err := s.handler(ctx, msg.Key, msg.Value)
+	recordHandler(methodHandle, *msg.TopicPartition.Topic, time.Since(start), err)
+	return err
+}
+
+// recordHandler records a handler call, topic is empty for a batch of several topics.
+func recordHandler(method, topic string, duration time.Duration, err error) {
+	code := codeSuccess
+	if err != nil {
+		code = metric.DefaultErr.Error()
+		if errors.Is(err, ErrNonRetryable) {
+			code = codeNonRetryable
+		}
+		metric.NewKafkaConsumerErrorCounter(method, topic, code)
+	}
+
+	metric.NewKafkaConsumerHistogramDuration(method, topic, code, duration)
+}
+
+func (s *Consumer) monitorLag(ctx context.Context) {
+	ticker := time.NewTicker(s.lagInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.recordLag(ctx); err != nil {
+				logger.GetLogger().Warn().Err(err).Msg("kafka record consumer lag failed")
+			}
+		}
+	}
+}
+
+// recordLag records, per assigned partition, the high watermark minus the committed offset.
+func (s *Consumer) recordLag(ctx context.Context) error {
+	assignment, err := s.cs.Assignment()
+	if err != nil {
+		return err
+	}
+	if len(assignment) == 0 {
+		s.maxLag.Store(0)
+		return nil
+	}
+
+	timeoutMs := int(lagQueryTimeout.Milliseconds())
+	committed, err := s.cs.Committed(assignment, timeoutMs)
+	if err != nil {
+		return err
+	}
+
+	var maxLag int64
+	for _, tp := range committed {
+		low, high, err := s.cs.QueryWatermarkOffsets(*tp.Topic, tp.Partition, timeoutMs)
+		if err != nil {
+			return err
+		}
+
+		// nothing committed yet, the whole partition is lagging
+		offset := int64(tp.Offset)
+		if offset < 0 {
+			offset = low
+		}
+
+		lag := max(high-offset, 0)
+		maxLag = max(maxLag, lag)
+
+		label := metric.NewLabel(
+			metric.WithComponent(metric.KafkaComponent),
+			metric.WithAttributes(metric.NewBiTags(
+				metric.TopicAttr, *tp.Topic,
+				metric.PartitionAttr, strconv.Itoa(int(tp.Partition)),
+			)),
+		)
+		metric.KafkaConsumerLagGauge.Record(ctx, lag, meter.WithAttributes(label.GetAttributes()...))
+	}
+
+	s.maxLag.Store(maxLag)
+	return nil
+}
+
+// HealthCheck fails when the lag of a partition exceeds the WithLagMonitor threshold,
+// it is meant to be used as a health probe.
+func (s *Consumer) HealthCheck(ctx context.Context) error {
+	if s.lagThreshold <= 0 {
+		return nil
+	}
+
+	if lag := s.maxLag.Load(); lag > s.lagThreshold {
+		return fmt.Errorf("%w: lag=%d threshold=%d", ErrLagExceeded, lag, s.lagThreshold)
+	}
+	return nil
+}
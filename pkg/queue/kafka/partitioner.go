@@ -0,0 +1,164 @@
+package kafka
+
+import (
+	logger "go-source/pkg/log"
+	"hash/crc32"
+	"math/rand/v2"
+	"sync"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+)
+
+const (
+	metadataTimeoutMs  = 5000
+	defaultStickyBatch = 100
+	murmur2Seed        = 0x9747b28c
+	murmur2Multiplier  = 0x5bd1e995
+	murmur2Shift       = 24
+	positiveMask       = 0x7fffffff
+)
+
+// Partitioner picks the partition of a message from its key.
+type Partitioner interface {
+	Partition(key []byte, numPartitions int32) int32
+}
+
+// CRC32Partitioner is crc32(key) % numPartitions, as PublishWithPartitionCRC32.
+type CRC32Partitioner struct{}
+
+func (CRC32Partitioner) Partition(key []byte, numPartitions int32) int32 {
+	partition := int32(crc32.ChecksumIEEE(key)) % numPartitions
+	if partition < 0 {
+		partition = -partition
+	}
+	return partition
+}
+
+// Murmur2Partitioner picks the same partition as the default partitioner of the Java
+// client for keyed messages. Messages without key get a random partition.
+type Murmur2Partitioner struct{}
+
+func (Murmur2Partitioner) Partition(key []byte, numPartitions int32) int32 {
+	if len(key) == 0 {
+		return rand.Int32N(numPartitions)
+	}
+	return int32(murmur2(key)&positiveMask) % numPartitions
+}
+
+// murmur2 is the Kafka Java client murmur2 hash.
+func murmur2(data []byte) uint32 {
+	length := len(data)
+	h := uint32(murmur2Seed) ^ uint32(length)
+
+	for i := 0; i+4 <= length; i += 4 {
+		k := uint32(data[i]) | uint32(data[i+1])<<8 | uint32(data[i+2])<<16 | uint32(data[i+3])<<24
+		k *= murmur2Multiplier
+		k ^= k >> murmur2Shift
+		k *= murmur2Multiplier
+		h *= murmur2Multiplier
+		h ^= k
+	}
+
+	tail := length &^ 3
+	switch length % 4 {
+	case 3:
+		h ^= uint32(data[tail+2]) << 16
+		fallthrough
+	case 2:
+		h ^= uint32(data[tail+1]) << 8
+		fallthrough
+	case 1:
+		h ^= uint32(data[tail])
+		h *= murmur2Multiplier
+	}
+
+	h ^= h >> 13
+	h *= murmur2Multiplier
+	h ^= h >> 15
+	return h
+}
+
+// StickyPartitioner sends messages without key to the same partition until
+// batchSize of them were sent, then switches to another one, so they are batched
+// together. Keyed messages use murmur2, like the Java client.
+type StickyPartitioner struct {
+	batchSize int
+	current   int32
+	count     int
+	mu        sync.Mutex
+}
+
+func NewStickyPartitioner(batchSize int) *StickyPartitioner {
+	if batchSize <= 0 {
+		batchSize = defaultStickyBatch
+	}
+	return &StickyPartitioner{batchSize: batchSize, current: -1}
+}
+
+func (p *StickyPartitioner) Partition(key []byte, numPartitions int32) int32 {
+	if len(key) > 0 {
+		return Murmur2Partitioner{}.Partition(key, numPartitions)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.current < 0 || p.current >= numPartitions || p.count >= p.batchSize {
+		next := rand.Int32N(numPartitions)
+		if numPartitions > 1 && next == p.current {
+			next = (next + 1) % numPartitions
+		}
+		p.current = next
+		p.count = 0
+	}
+
+	p.count++
+	return p.current
+}
+
+// SetPartitioner sets the partitioner of Publish, PublishSync, PublishAsync,
+// PublishWithTopic and PublishBytes. Without partitioner librdkafka picks the partition.
+func (s *Producer) SetPartitioner(partitioner Partitioner) {
+	s.mu.Lock()
+	s.partitioner = partitioner
+	s.mu.Unlock()
+}
+
+func (s *Producer) partition(topic string, key []byte) int32 {
+	s.mu.RLock()
+	partitioner := s.partitioner
+	s.mu.RUnlock()
+
+	if partitioner == nil {
+		return kafka.PartitionAny
+	}
+
+	numPartitions := s.partitionCount(topic)
+	if numPartitions <= 0 {
+		return kafka.PartitionAny
+	}
+	return partitioner.Partition(key, numPartitions)
+}
+
+// partitionCount returns the number of partitions of topic, read from the metadata
+// once per topic unless it was given to NewProducer.
+func (s *Producer) partitionCount(topic string) int32 {
+	if topic == s.topic && s.numPartitions > 0 {
+		return s.numPartitions
+	}
+	if v, ok := s.partitions.Load(topic); ok {
+		return v.(int32)
+	}
+
+	metadata, err := s.pr.GetMetadata(&topic, false, metadataTimeoutMs)
+	if err != nil {
+		logger.GetLogger().Warn().Err(err).Msgf("kafka get metadata failed : TOPIC = %v", topic)
+		return 0
+	}
+
+	numPartitions := int32(len(metadata.Topics[topic].Partitions))
+	if numPartitions > 0 {
+		s.partitions.Store(topic, numPartitions)
+	}
+	return numPartitions
+}
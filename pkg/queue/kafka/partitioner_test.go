@@ -0,0 +1,34 @@
+package kafka
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMurmur2(t *testing.T) {
+	// expected values from the Kafka Java client tests
+	cases := map[string]int32{
+		"21":                         -973932308,
+		"foobar":                     -790332482,
+		"a-little-bit-long-string":   -985981536,
+		"a-little-bit-longer-string": -1486304829,
+		"lkjh234lh9fiuh90y23oiuhsafujhadof229phr9h19h89h8": -58897971,
+		"abc": 479470107,
+	}
+
+	for key, expected := range cases {
+		assert.Equal(t, expected, int32(murmur2([]byte(key))), key)
+	}
+}
+
+func TestStickyPartitioner(t *testing.T) {
+	p := NewStickyPartitioner(3)
+
+	first := p.Partition(nil, 4)
+	assert.Equal(t, first, p.Partition(nil, 4))
+	assert.Equal(t, first, p.Partition(nil, 4))
+	assert.NotEqual(t, first, p.Partition(nil, 4))
+
+	assert.Equal(t, Murmur2Partitioner{}.Partition([]byte("key"), 4), p.Partition([]byte("key"), 4))
+}
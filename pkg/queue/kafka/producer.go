@@ -5,19 +5,48 @@ import (
 	"encoding/json"
 	logger "go-source/pkg/log"
 	"go-source/pkg/utils"
-	"hash/crc32"
+	"sync"
 
 	"github.com/confluentinc/confluent-kafka-go/kafka"
 )
 
-type ProducerInterface interface {
+// PublisherInterface is the part of ProducerInterface implemented by other queues too.
+type PublisherInterface interface {
 	Publish(ctx context.Context, key, value interface{}) error
 }
 
+type ProducerInterface interface {
+	PublisherInterface
+	PublishSync(ctx context.Context, key, value interface{}) error
+	PublishAsync(ctx context.Context, key, value interface{}, cb DeliveryCallback) error
+	PublishWithPartition(ctx context.Context, key, value interface{}, partition int32) error
+	PublishWithPartitionCRC32(ctx context.Context, key, value interface{}) error
+	PublishWithTopic(ctx context.Context, topic string, key, value interface{}) error
+	PublishBytes(ctx context.Context, key, value []byte) error
+	PublishMessage(ctx context.Context, msg *kafka.Message) error
+	PublishMessageSync(ctx context.Context, msg *kafka.Message) error
+	GetTopicName() string
+	Close(ctx context.Context) error
+}
+
+var _ ProducerInterface = (*Producer)(nil)
+
 type Producer struct {
 	pr            *kafka.Producer
 	topic         string
 	numPartitions int32
+
+	partitioner Partitioner
+	partitions  sync.Map
+
+	onDelivery DeliveryCallback
+	eventsDone chan struct{}
+	mu         sync.RWMutex
+
+	// closeMu is held for reading by produce, Close takes it for writing so no
+	// message is being produced once closed is set
+	closed  bool
+	closeMu sync.RWMutex
 }
 
 func NewProducer(cfg KafkaConfig, topic string, numPartitions ...int32) *Producer {
@@ -56,14 +85,17 @@ func NewProducer(cfg KafkaConfig, topic string, numPartitions ...int32) *Produce
 	log.Info().Msgf("init kafka producer success : TOPIC = %v", topic)
 
 	res := &Producer{
-		pr:    pr,
-		topic: topic,
+		pr:         pr,
+		topic:      topic,
+		eventsDone: make(chan struct{}),
 	}
 
 	if len(numPartitions) > 0 {
 		res.numPartitions = numPartitions[0]
 	}
 
+	go res.eventsLoop()
+
 	return res
 }
 
@@ -79,13 +111,24 @@ func marshal(val interface{}) ([]byte, error) {
 }
 
 func (s *Producer) Publish(ctx context.Context, key, value interface{}) error {
-	keyData, err := marshal(key)
+	msg, err := s.newMessage(ctx, s.topic, key, value)
 	if err != nil {
 		return err
 	}
+	msg.TopicPartition.Partition = s.partition(s.topic, msg.Key)
+	return s.produce(msg, nil)
+}
+
+// newMessage marshals key and value into a message for topic, with the trace info
+// header and the headers of ctx.
+func (s *Producer) newMessage(ctx context.Context, topic string, key, value interface{}) (*kafka.Message, error) {
+	keyData, err := marshal(key)
+	if err != nil {
+		return nil, err
+	}
 	valueData, err := marshal(value)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	var header []byte
@@ -93,158 +136,74 @@ func (s *Producer) Publish(ctx context.Context, key, value interface{}) error {
 	if traceInfo != nil {
 		header, err = marshal(traceInfo)
 		if err != nil {
-			return err
+			return nil, err
 		}
 	}
 
-	msg := &kafka.Message{
-		Key:   keyData,
-		Value: valueData,
-		Headers: []kafka.Header{
-			{
-				Key:   utils.KeyTraceInfo,
-				Value: header,
-			},
+	headers := []kafka.Header{
+		{
+			Key:   utils.KeyTraceInfo,
+			Value: header,
 		},
+	}
+
+	return &kafka.Message{
+		Key:     keyData,
+		Value:   valueData,
+		Headers: append(headers, contextHeaders(ctx)...),
 		TopicPartition: kafka.TopicPartition{
-			Topic:     &s.topic,
+			Topic:     &topic,
 			Partition: kafka.PartitionAny,
 		},
-	}
-	if err = s.pr.Produce(msg, nil); err != nil {
-		return err
-	}
-	return nil
+	}, nil
 }
 
 func (s *Producer) PublishWithPartition(ctx context.Context, key, value interface{}, partition int32) error {
-	keyData, err := marshal(key)
+	msg, err := s.newMessage(ctx, s.topic, key, value)
 	if err != nil {
 		return err
 	}
-	valueData, err := marshal(value)
-	if err != nil {
-		return err
-	}
-
-	msg := &kafka.Message{
-		Key:   keyData,
-		Value: valueData,
-		TopicPartition: kafka.TopicPartition{
-			Topic:     &s.topic,
-			Partition: partition,
-		},
-	}
-	if err = s.pr.Produce(msg, nil); err != nil {
-		return err
-	}
-	return nil
+	msg.TopicPartition.Partition = partition
+	return s.produce(msg, nil)
 }
 
 // PublishWithPartitionCRC32 publish message with partition is crc32(key) % numPartitions
 func (s *Producer) PublishWithPartitionCRC32(ctx context.Context, key, value interface{}) error {
-	keyData, err := marshal(key)
+	msg, err := s.newMessage(ctx, s.topic, key, value)
 	if err != nil {
 		return err
 	}
 
-	valueData, err := marshal(value)
-	if err != nil {
-		return err
-	}
-
-	var header []byte
-	traceInfo := utils.GetRequestIdByContext(ctx)
-	if traceInfo != nil {
-		header, err = marshal(traceInfo)
-		if err != nil {
-			return err
-		}
-	}
-
-	partition := kafka.PartitionAny
 	if s.numPartitions > 0 {
-		partition = int32(crc32.ChecksumIEEE(keyData)) % s.numPartitions
-		if partition < 0 {
-			partition = -partition
-		}
-	}
-
-	msg := &kafka.Message{
-		Key:   keyData,
-		Value: valueData,
-		Headers: []kafka.Header{
-			{
-				Key:   utils.KeyTraceInfo,
-				Value: header,
-			},
-		},
-		TopicPartition: kafka.TopicPartition{
-			Topic:     &s.topic,
-			Partition: partition,
-		},
-	}
-	if err = s.pr.Produce(msg, nil); err != nil {
-		return err
+		msg.TopicPartition.Partition = CRC32Partitioner{}.Partition(msg.Key, s.numPartitions)
 	}
-
-	return nil
+	return s.produce(msg, nil)
 }
 
 func (s *Producer) PublishBytes(ctx context.Context, key, value []byte) error {
 	msg := &kafka.Message{
-		Key:   key,
-		Value: value,
+		Key:     key,
+		Value:   value,
+		Headers: contextHeaders(ctx),
 		TopicPartition: kafka.TopicPartition{
 			Topic:     &s.topic,
-			Partition: kafka.PartitionAny,
+			Partition: s.partition(s.topic, key),
 		},
 	}
-	return s.pr.Produce(msg, nil)
+	return s.produce(msg, nil)
 }
 
 func (s *Producer) PublishMessage(ctx context.Context, msg *kafka.Message) error {
-	return s.pr.Produce(msg, nil)
+	return s.produce(msg, nil)
 }
 
 func (s *Producer) PublishWithTopic(ctx context.Context, topic string, key, value interface{}) error {
-	keyData, err := marshal(key)
+	msg, err := s.newMessage(ctx, topic, key, value)
 	if err != nil {
 		return err
 	}
-	valueData, err := marshal(value)
-	if err != nil {
-		return err
-	}
-
-	var header []byte
-	traceInfo := utils.GetRequestIdByContext(ctx)
-	if traceInfo != nil {
-		header, err = marshal(traceInfo)
-		if err != nil {
-			return err
-		}
-	}
-
-	msg := &kafka.Message{
-		Value: valueData,
-		Key:   keyData,
-		Headers: []kafka.Header{
-			{
-				Key:   utils.KeyTraceInfo,
-				Value: header,
-			},
-		},
-		TopicPartition: kafka.TopicPartition{
-			Topic:     &topic,
-			Partition: kafka.PartitionAny,
-		},
-	}
-
-	if err := s.pr.Produce(msg, nil); err != nil {
-		return err
-	}
-	return nil
+	msg.TopicPartition.Partition = s.partition(topic, msg.Key)
+	return s.produce(msg, nil)
 }
 
 func (s *Producer) GetTopicName() string {
@@ -0,0 +1,96 @@
+package kafka
+
+import (
+	logger "go-source/pkg/log"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+)
+
+const defaultRevokeTimeout = 30 * time.Second
+
+// RebalanceListener is notified after the consumer handled a rebalance:
+// OnRevoked runs once in-flight messages of the revoked partitions finished
+// and their offsets were committed.
+type RebalanceListener struct {
+	OnAssigned func(partitions []kafka.TopicPartition)
+	OnRevoked  func(partitions []kafka.TopicPartition)
+}
+
+func WithRebalanceListener(listener RebalanceListener) ConsumerOption {
+	return func(s *Consumer) {
+		s.rebalanceListener = &listener
+	}
+}
+
+// WithRevokeTimeout bounds how long a revoke waits for in-flight messages.
+// It must stay below max.poll.interval.ms.
+func WithRevokeTimeout(timeout time.Duration) ConsumerOption {
+	return func(s *Consumer) {
+		s.revokeTimeout = timeout
+	}
+}
+
+func (s *Consumer) rebalance(_ *kafka.Consumer, event kafka.Event) error {
+	log := logger.GetLogger()
+
+	switch e := event.(type) {
+	case kafka.AssignedPartitions:
+		log.Info().Msgf("kafka partitions assigned: %v", e.Partitions)
+		if s.rebalanceListener != nil && s.rebalanceListener.OnAssigned != nil {
+			s.rebalanceListener.OnAssigned(e.Partitions)
+		}
+
+	case kafka.RevokedPartitions:
+		log.Info().Msgf("kafka partitions revoked: %v", e.Partitions)
+		s.revoke(e.Partitions)
+		if s.rebalanceListener != nil && s.rebalanceListener.OnRevoked != nil {
+			s.rebalanceListener.OnRevoked(e.Partitions)
+		}
+	}
+
+	return nil
+}
+
+// revoke finishes the work of partitions being taken away: it waits for their
+// in-flight messages, commits what was processed and forgets their state. In batch
+// mode their messages are dropped from the batch being collected.
+func (s *Consumer) revoke(partitions []kafka.TopicPartition) {
+	log := logger.GetLogger()
+
+	s.dropPaused(partitions)
+	if s.batchHandler != nil {
+		s.dropBatch(partitions)
+	}
+
+	if s.tracker == nil {
+		return
+	}
+
+	if !s.tracker.waitIdle(partitions, s.revokeTimeout) {
+		log.Warn().Msgf("kafka revoke timeout, in-flight messages will be redelivered: %v", partitions)
+	}
+
+	s.commitCompleted()
+	s.tracker.remove(partitions)
+}
+
+func (s *Consumer) dropPaused(partitions []kafka.TopicPartition) {
+	revoked := partitionSet(partitions)
+
+	remaining := s.paused[:0]
+	for _, p := range s.paused {
+		if !revoked[partitionKey{topic: *p.tp.Topic, partition: p.tp.Partition}] {
+			remaining = append(remaining, p)
+		}
+	}
+	s.paused = remaining
+}
+
+func partitionSet(partitions []kafka.TopicPartition) map[partitionKey]bool {
+	set := make(map[partitionKey]bool, len(partitions))
+	for _, tp := range partitions {
+		set[partitionKey{topic: *tp.Topic, partition: tp.Partition}] = true
+	}
+	return set
+}
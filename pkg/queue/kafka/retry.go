@@ -0,0 +1,323 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	logger "go-source/pkg/log"
+	"strconv"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+)
+
+const (
+	HeaderRetryAttempt      = "x-retry-attempt"
+	HeaderRetryTier         = "x-retry-tier"
+	HeaderRetryNotBefore    = "x-retry-not-before"
+	HeaderOriginalTopic     = "x-original-topic"
+	HeaderOriginalPartition = "x-original-partition"
+	HeaderOriginalOffset    = "x-original-offset"
+	HeaderError             = "x-error"
+	HeaderFailedAt          = "x-failed-at"
+
+	retryTopicInfix = ".retry."
+	dlqTopicSuffix  = ".dlq"
+
+	// defaultMaxBackoff is used when the backoff overflows and MaxBackoff is not set
+	defaultMaxBackoff = 30 * time.Second
+	// defaultMaxInPlaceWait stays well below the default max.poll.interval.ms of 5m
+	defaultMaxInPlaceWait = time.Minute
+)
+
+// ErrNonRetryable marks a handler error that goes straight to the DLQ.
+var ErrNonRetryable = errors.New("non retryable")
+
+// NonRetryable wraps err so the consumer skips the retries and sends the message to the DLQ.
+func NonRetryable(err error) error {
+	return fmt.Errorf("%w: %w", ErrNonRetryable, err)
+}
+
+// RetryTier is a retry topic named <topic>.retry.<Suffix>, whose messages are
+// handled again Delay after they were published, e.g. {"1m", time.Minute}.
+type RetryTier struct {
+	Suffix string
+	Delay  time.Duration
+}
+
+// RetryPolicy drives what the consumer does when the handler returns an error:
+// retry in place MaxAttempts times with exponential backoff, then move the message
+// to the next retry tier, then to the DLQ topic.
+type RetryPolicy struct {
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	Tiers          []RetryTier
+	// DLQTopic defaults to <topic>.dlq
+	DLQTopic string
+	// MaxInPlaceWait caps the backoff slept in place for one message, the poll loop
+	// is blocked meanwhile so it must stay below max.poll.interval.ms. Defaults to 1m.
+	MaxInPlaceWait time.Duration
+}
+
+func (p *RetryPolicy) backoff(attempt int) time.Duration {
+	if p.InitialBackoff <= 0 {
+		return 0
+	}
+
+	backoff := p.InitialBackoff << (attempt - 1)
+	if backoff>>(attempt-1) != p.InitialBackoff {
+		// the shift overflowed
+		if p.MaxBackoff > 0 {
+			return p.MaxBackoff
+		}
+		return defaultMaxBackoff
+	}
+	if p.MaxBackoff > 0 && backoff > p.MaxBackoff {
+		backoff = p.MaxBackoff
+	}
+	return backoff
+}
+
+// inPlaceWait returns the backoff before the attempt after attempt, and false when
+// it would take the in-place wait, waited included, over MaxInPlaceWait.
+func (p *RetryPolicy) inPlaceWait(attempt int, waited time.Duration) (time.Duration, bool) {
+	limit := p.MaxInPlaceWait
+	if limit <= 0 {
+		limit = defaultMaxInPlaceWait
+	}
+
+	backoff := p.backoff(attempt)
+	return backoff, waited+backoff <= limit
+}
+
+func (p *RetryPolicy) retryTopics(topics []string) []string {
+	var res []string
+	for _, topic := range topics {
+		for _, tier := range p.Tiers {
+			res = append(res, retryTopicName(topic, tier))
+		}
+	}
+	return res
+}
+
+func (p *RetryPolicy) dlqTopic(topic string) string {
+	if p.DLQTopic != "" {
+		return p.DLQTopic
+	}
+	return topic + dlqTopicSuffix
+}
+
+func retryTopicName(topic string, tier RetryTier) string {
+	return topic + retryTopicInfix + tier.Suffix
+}
+
+// handleWithRetry runs the handler, retrying in place according to the policy.
+// It returns the last error and the number of attempts made.
+func (s *Consumer) handleWithRetry(ctx context.Context, msg *kafka.Message) (int, error) {
+	maxAttempts := 1
+	if s.retry != nil && s.retry.MaxAttempts > 1 {
+		maxAttempts = s.retry.MaxAttempts
+	}
+
+	var (
+		err    error
+		waited time.Duration
+	)
+	attempt := 0
+	for attempt < maxAttempts {
+		attempt++
+		if err = s.handle(ctx, msg); err == nil || errors.Is(err, ErrNonRetryable) {
+			return attempt, err
+		}
+
+		if attempt < maxAttempts {
+			backoff, ok := s.retry.inPlaceWait(attempt, waited)
+			if !ok {
+				// the retry tiers take over rather than stalling the poll loop
+				return attempt, err
+			}
+			waited += backoff
+
+			select {
+			case <-ctx.Done():
+				return attempt, err
+			case <-time.After(backoff):
+			}
+		}
+	}
+
+	return attempt, err
+}
+
+// routeFailed publishes a message whose handling failed to the next retry tier,
+// or to the DLQ when the tiers are exhausted or the error is not retryable.
+func (s *Consumer) routeFailed(ctx context.Context, msg *kafka.Message, attempts int, handlerErr error) error {
+	log := logger.GetLogger().AddTraceInfoContextRequest(ctx)
+
+	originalTopic := headerValue(msg.Headers, HeaderOriginalTopic)
+	if originalTopic == "" {
+		originalTopic = *msg.TopicPartition.Topic
+	}
+
+	tier := -1
+	if v := headerValue(msg.Headers, HeaderRetryTier); v != "" {
+		tier, _ = strconv.Atoi(v)
+	}
+
+	totalAttempts := attempts
+	if v := headerValue(msg.Headers, HeaderRetryAttempt); v != "" {
+		prev, _ := strconv.Atoi(v)
+		totalAttempts += prev
+	}
+
+	headers := copyHeaders(msg.Headers)
+	headers = setHeader(headers, HeaderRetryAttempt, strconv.Itoa(totalAttempts))
+	headers = setHeader(headers, HeaderError, handlerErr.Error())
+	headers = setHeader(headers, HeaderFailedAt, time.Now().UTC().Format(time.RFC3339Nano))
+	if headerValue(msg.Headers, HeaderOriginalTopic) == "" {
+		headers = setHeader(headers, HeaderOriginalTopic, originalTopic)
+		headers = setHeader(headers, HeaderOriginalPartition, strconv.Itoa(int(msg.TopicPartition.Partition)))
+		headers = setHeader(headers, HeaderOriginalOffset, msg.TopicPartition.Offset.String())
+	}
+
+	var topic string
+	nextTier := tier + 1
+	if !errors.Is(handlerErr, ErrNonRetryable) && nextTier < len(s.retry.Tiers) {
+		next := s.retry.Tiers[nextTier]
+		topic = retryTopicName(originalTopic, next)
+		headers = setHeader(headers, HeaderRetryTier, strconv.Itoa(nextTier))
+		headers = setHeader(headers, HeaderRetryNotBefore, strconv.FormatInt(time.Now().Add(next.Delay).UnixMilli(), 10))
+		log.Warn().Err(handlerErr).Msgf("kafka message moved to retry topic %s: attempts=%d", topic, totalAttempts)
+	} else {
+		topic = s.retry.dlqTopic(originalTopic)
+		headers = removeHeader(headers, HeaderRetryNotBefore)
+		log.Error().Err(handlerErr).Msgf("kafka message moved to dlq topic %s: attempts=%d", topic, totalAttempts)
+	}
+
+	return s.retryProducer.PublishMessageSync(ctx, &kafka.Message{
+		Key:     msg.Key,
+		Value:   msg.Value,
+		Headers: headers,
+		TopicPartition: kafka.TopicPartition{
+			Topic:     &topic,
+			Partition: kafka.PartitionAny,
+		},
+	})
+}
+
+// routeUntilDone keeps trying to route the failed message, committing past it
+// would lose it. It gives up only when ctx, the consumer context, is done.
+func (s *Consumer) routeUntilDone(ctx, msgCtx context.Context, msg *kafka.Message, attempts int, handlerErr error) bool {
+	log := logger.GetLogger().AddTraceInfoContextRequest(msgCtx)
+
+	for {
+		err := s.routeFailed(msgCtx, msg, attempts, handlerErr)
+		if err == nil {
+			return true
+		}
+
+		log.Err(err).Msg("kafka publish retry message failed")
+		select {
+		case <-ctx.Done():
+			return false
+		case <-time.After(max(s.retry.backoff(1), time.Second)):
+		}
+	}
+}
+
+// delayIfNotDue pauses the partition of a retry tier message that is not due yet
+// and rewinds it, so the message is read again once the partition is resumed.
+func (s *Consumer) delayIfNotDue(msg *kafka.Message) bool {
+	v := headerValue(msg.Headers, HeaderRetryNotBefore)
+	if v == "" {
+		return false
+	}
+
+	notBefore, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return false
+	}
+
+	wait := time.Until(time.UnixMilli(notBefore))
+	if wait <= 0 {
+		return false
+	}
+
+	log := logger.GetLogger()
+	tp := msg.TopicPartition
+	if err = s.cs.Pause([]kafka.TopicPartition{tp}); err != nil {
+		log.Warn().Err(err).Msgf("kafka pause partition failed: %v", tp)
+		return false
+	}
+
+	if err = s.cs.Seek(tp, 0); err != nil {
+		log.Warn().Err(err).Msgf("kafka seek partition failed: %v", tp)
+	}
+
+	s.paused = append(s.paused, pausedPartition{tp: tp, resumeAt: time.Now().Add(wait)})
+	return true
+}
+
+// resumeDue resumes paused retry partitions whose delay is over.
+func (s *Consumer) resumeDue() {
+	if len(s.paused) == 0 {
+		return
+	}
+
+	log := logger.GetLogger()
+	now := time.Now()
+	remaining := s.paused[:0]
+	for _, p := range s.paused {
+		if now.Before(p.resumeAt) {
+			remaining = append(remaining, p)
+			continue
+		}
+
+		if err := s.cs.Resume([]kafka.TopicPartition{p.tp}); err != nil {
+			log.Warn().Err(err).Msgf("kafka resume partition failed: %v", p.tp)
+			remaining = append(remaining, p)
+		}
+	}
+	s.paused = remaining
+}
+
+type pausedPartition struct {
+	tp       kafka.TopicPartition
+	resumeAt time.Time
+}
+
+func headerValue(headers []kafka.Header, key string) string {
+	for _, h := range headers {
+		if h.Key == key {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
+
+func copyHeaders(headers []kafka.Header) []kafka.Header {
+	res := make([]kafka.Header, len(headers))
+	copy(res, headers)
+	return res
+}
+
+func setHeader(headers []kafka.Header, key, value string) []kafka.Header {
+	for i, h := range headers {
+		if h.Key == key {
+			headers[i].Value = []byte(value)
+			return headers
+		}
+	}
+	return append(headers, kafka.Header{Key: key, Value: []byte(value)})
+}
+
+func removeHeader(headers []kafka.Header, key string) []kafka.Header {
+	res := headers[:0]
+	for _, h := range headers {
+		if h.Key != key {
+			res = append(res, h)
+		}
+	}
+	return res
+}
@@ -0,0 +1,28 @@
+package kafka
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	policy := RetryPolicy{InitialBackoff: time.Second}
+	assert.Equal(t, 4*time.Second, policy.backoff(3))
+	// the shift overflows, without MaxBackoff the default cap applies
+	assert.Equal(t, defaultMaxBackoff, policy.backoff(40))
+	assert.Equal(t, defaultMaxBackoff, policy.backoff(100))
+
+	policy.MaxBackoff = 10 * time.Second
+	assert.Equal(t, 10*time.Second, policy.backoff(5))
+	assert.Equal(t, 10*time.Second, policy.backoff(100))
+
+	// the in-place wait stops before going over MaxInPlaceWait
+	policy.MaxInPlaceWait = 15 * time.Second
+	backoff, ok := policy.inPlaceWait(4, 0)
+	assert.True(t, ok)
+	assert.Equal(t, 8*time.Second, backoff)
+	_, ok = policy.inPlaceWait(5, 8*time.Second)
+	assert.False(t, ok)
+}
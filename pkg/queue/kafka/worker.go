@@ -0,0 +1,274 @@
+package kafka
+
+import (
+	"context"
+	logger "go-source/pkg/log"
+	"hash/fnv"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+)
+
+const (
+	OrderByPartition OrderBy = iota
+	OrderByKey
+)
+
+const (
+	commitInterval            = time.Second
+	defaultInFlightPerWorkers = 10
+)
+
+// OrderBy chooses what keeps its order when messages are processed in parallel:
+// messages of the same partition, or only messages with the same key.
+type OrderBy int
+
+// WithConcurrency processes messages with a pool of workers. Messages with the same
+// partition (or key) always go to the same worker, so their order is preserved.
+// maxInFlight bounds the messages read but not processed yet; reading blocks
+// when it is reached.
+func WithConcurrency(workers, maxInFlight int, orderBy OrderBy) ConsumerOption {
+	return func(s *Consumer) {
+		if maxInFlight <= 0 {
+			maxInFlight = workers * defaultInFlightPerWorkers
+		}
+		s.workers = workers
+		s.maxInFlight = maxInFlight
+		s.orderBy = orderBy
+	}
+}
+
+func (s *Consumer) startWorkers(ctx context.Context) {
+	s.inFlight = make(chan struct{}, s.maxInFlight)
+	s.tracker = newOffsetTracker()
+	s.queues = make([]chan *kafka.Message, s.workers)
+
+	for i := range s.queues {
+		s.queues[i] = make(chan *kafka.Message, s.maxInFlight)
+		s.wg.Add(1)
+		go s.work(ctx, s.queues[i])
+	}
+
+	s.wg.Add(1)
+	go s.commitLoop(ctx)
+}
+
+// stopWorkers lets the workers finish the queued messages, then commits them.
+func (s *Consumer) stopWorkers() {
+	for _, queue := range s.queues {
+		close(queue)
+	}
+	s.wg.Wait()
+	s.commitCompleted()
+}
+
+func (s *Consumer) work(ctx context.Context, queue chan *kafka.Message) {
+	defer s.wg.Done()
+	for msg := range queue {
+		// without retry policy a failed message is marked done as well, like the
+		// sequential mode commits past it with the next message
+		if s.process(ctx, msg) || s.retry == nil {
+			s.tracker.done(msg.TopicPartition)
+		} else {
+			s.tracker.abort(msg.TopicPartition)
+		}
+		<-s.inFlight
+	}
+}
+
+// dispatch hands the message to its worker, blocking while maxInFlight is reached.
+func (s *Consumer) dispatch(ctx context.Context, msg *kafka.Message) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case s.inFlight <- struct{}{}:
+	}
+
+	s.tracker.start(msg.TopicPartition)
+	s.queues[s.workerIndex(msg)] <- msg
+	return nil
+}
+
+func (s *Consumer) workerIndex(msg *kafka.Message) int {
+	h := fnv.New32a()
+	if s.orderBy == OrderByKey && len(msg.Key) > 0 {
+		_, _ = h.Write(msg.Key)
+	} else {
+		_, _ = h.Write([]byte(*msg.TopicPartition.Topic))
+		_, _ = h.Write([]byte(strconv.Itoa(int(msg.TopicPartition.Partition))))
+	}
+	return int(h.Sum32() % uint32(s.workers))
+}
+
+func (s *Consumer) commitLoop(ctx context.Context) {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(commitInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.commitCompleted()
+		}
+	}
+}
+
+// commitCompleted commits, per partition, the offset after the last contiguous processed message.
+func (s *Consumer) commitCompleted() {
+	offsets := s.tracker.committable()
+	if len(offsets) == 0 {
+		return
+	}
+
+	if _, err := s.cs.CommitOffsets(offsets); err != nil {
+		logger.GetLogger().Err(err).Msgf("kafka commit offsets failed: %v", offsets)
+		return
+	}
+
+	s.tracker.committed(offsets)
+}
+
+type partitionKey struct {
+	topic     string
+	partition int32
+}
+
+type partitionOffsets struct {
+	// offsets dispatched and not processed yet
+	inFlight map[kafka.Offset]struct{}
+	// offset after the highest dispatched message
+	next kafka.Offset
+	// lowest offset whose processing was aborted, commits never go past it
+	aborted *kafka.Offset
+	// offset last committed
+	commit kafka.Offset
+}
+
+// offsetTracker tracks in-flight offsets per partition. Messages are dispatched in
+// offset order, so every offset below the lowest in-flight one has been processed.
+type offsetTracker struct {
+	mu         sync.Mutex
+	partitions map[partitionKey]*partitionOffsets
+}
+
+func newOffsetTracker() *offsetTracker {
+	return &offsetTracker{partitions: make(map[partitionKey]*partitionOffsets)}
+}
+
+func (t *offsetTracker) start(tp kafka.TopicPartition) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	key := partitionKey{topic: *tp.Topic, partition: tp.Partition}
+	p, ok := t.partitions[key]
+	if !ok {
+		p = &partitionOffsets{inFlight: make(map[kafka.Offset]struct{}), commit: kafka.OffsetInvalid}
+		t.partitions[key] = p
+	}
+
+	p.inFlight[tp.Offset] = struct{}{}
+	if tp.Offset+1 > p.next {
+		p.next = tp.Offset + 1
+	}
+}
+
+func (t *offsetTracker) done(tp kafka.TopicPartition) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if p, ok := t.partitions[partitionKey{topic: *tp.Topic, partition: tp.Partition}]; ok {
+		delete(p.inFlight, tp.Offset)
+	}
+}
+
+func (t *offsetTracker) abort(tp kafka.TopicPartition) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if p, ok := t.partitions[partitionKey{topic: *tp.Topic, partition: tp.Partition}]; ok {
+		delete(p.inFlight, tp.Offset)
+		if p.aborted == nil || tp.Offset < *p.aborted {
+			offset := tp.Offset
+			p.aborted = &offset
+		}
+	}
+}
+
+func (t *offsetTracker) committable() []kafka.TopicPartition {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var res []kafka.TopicPartition
+	for key, p := range t.partitions {
+		offset := p.next
+		if p.aborted != nil && *p.aborted < offset {
+			offset = *p.aborted
+		}
+		for o := range p.inFlight {
+			if o < offset {
+				offset = o
+			}
+		}
+
+		if offset <= p.commit {
+			continue
+		}
+
+		topic := key.topic
+		res = append(res, kafka.TopicPartition{Topic: &topic, Partition: key.partition, Offset: offset})
+	}
+
+	return res
+}
+
+func (t *offsetTracker) committed(offsets []kafka.TopicPartition) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, tp := range offsets {
+		if p, ok := t.partitions[partitionKey{topic: *tp.Topic, partition: tp.Partition}]; ok && tp.Offset > p.commit {
+			p.commit = tp.Offset
+		}
+	}
+}
+
+// waitIdle waits until the partitions have no message in flight, or timeout.
+func (t *offsetTracker) waitIdle(partitions []kafka.TopicPartition, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for {
+		if t.idle(partitions) {
+			return true
+		}
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func (t *offsetTracker) idle(partitions []kafka.TopicPartition) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, tp := range partitions {
+		if p, ok := t.partitions[partitionKey{topic: *tp.Topic, partition: tp.Partition}]; ok && len(p.inFlight) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// remove forgets partitions that are no longer assigned.
+func (t *offsetTracker) remove(partitions []kafka.TopicPartition) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, tp := range partitions {
+		delete(t.partitions, partitionKey{topic: *tp.Topic, partition: tp.Partition})
+	}
+}
@@ -0,0 +1,39 @@
+package kafka
+
+import (
+	"testing"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOffsetTracker_Committable(t *testing.T) {
+	topic := "topic"
+	tp := func(offset kafka.Offset) kafka.TopicPartition {
+		return kafka.TopicPartition{Topic: &topic, Partition: 0, Offset: offset}
+	}
+
+	tracker := newOffsetTracker()
+	for _, o := range []kafka.Offset{10, 11, 12, 13} {
+		tracker.start(tp(o))
+	}
+
+	// out of order completion does not move past the lowest in-flight offset
+	tracker.done(tp(12))
+	tracker.done(tp(11))
+	offsets := tracker.committable()
+	assert.Len(t, offsets, 1)
+	assert.Equal(t, kafka.Offset(10), offsets[0].Offset)
+	tracker.committed(offsets)
+
+	assert.Empty(t, tracker.committable())
+
+	tracker.done(tp(10))
+	offsets = tracker.committable()
+	assert.Equal(t, kafka.Offset(13), offsets[0].Offset)
+	tracker.committed(offsets)
+
+	tracker.done(tp(13))
+	offsets = tracker.committable()
+	assert.Equal(t, kafka.Offset(14), offsets[0].Offset)
+}
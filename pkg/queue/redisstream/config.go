@@ -0,0 +1,15 @@
+package redisstream
+
+import "time"
+
+type RedisStreamConfig struct {
+	GroupID         string        `env:"GROUP_ID"`
+	ConsumerName    string        `env:"CONSUMER_NAME"`
+	MaxLen          int64         `env:"MAX_LEN"`
+	BatchSize       int64         `env:"BATCH_SIZE"`
+	BlockTime       time.Duration `env:"BLOCK_TIME"`
+	ReclaimMinIdle  time.Duration `env:"RECLAIM_MIN_IDLE"`
+	ReclaimInterval time.Duration `env:"RECLAIM_INTERVAL"`
+	// MaxDeliveries moves a message reclaimed more often to the <stream>.dlq stream
+	MaxDeliveries int64 `env:"MAX_DELIVERIES"`
+}
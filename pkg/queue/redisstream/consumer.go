@@ -0,0 +1,355 @@
+package redisstream
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	logger "go-source/pkg/log"
+	"go-source/pkg/queue/kafka"
+	"go-source/pkg/utils"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	defaultBatchSize       = 10
+	defaultBlockTime       = 5 * time.Second
+	defaultReclaimMinIdle  = time.Minute
+	defaultReclaimInterval = 30 * time.Second
+	defaultMaxDeliveries   = 5
+
+	dlqStreamSuffix = ".dlq"
+	fieldStream     = "stream"
+	fieldID         = "id"
+	fieldDeliveries = "deliveries"
+
+	// startFromBeginning is the id a new group starts reading from,
+	// so messages published before the first consumer started are not lost.
+	startFromBeginning = "0"
+)
+
+var (
+	ErrEmptyGroupID = errors.New("redis stream group id is empty")
+)
+
+var _ kafka.ConsumerInterface = (*Consumer)(nil)
+
+// Consumer reads streams through a consumer group. Messages are acked only when
+// the handler succeeds; failed messages stay pending and are reclaimed after
+// ReclaimMinIdle, together with the pending messages of crashed consumers. A message
+// delivered more than MaxDeliveries times is moved to the <stream>.dlq stream.
+type Consumer struct {
+	client  redis.UniversalClient
+	cfg     RedisStreamConfig
+	streams []string
+	handler kafka.OnEventHandler
+
+	started bool
+	cancel  context.CancelFunc
+	done    chan struct{}
+	mu      sync.RWMutex
+}
+
+func NewConsumer(client redis.UniversalClient, cfg RedisStreamConfig, streams []string) *Consumer {
+	log := logger.GetLogger()
+
+	if cfg.ConsumerName == "" {
+		hostname, _ := os.Hostname()
+		cfg.ConsumerName = fmt.Sprintf("%s-%s", hostname, utils.RandString())
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = defaultBatchSize
+	}
+	if cfg.BlockTime <= 0 {
+		cfg.BlockTime = defaultBlockTime
+	}
+	if cfg.ReclaimMinIdle <= 0 {
+		cfg.ReclaimMinIdle = defaultReclaimMinIdle
+	}
+	if cfg.ReclaimInterval <= 0 {
+		cfg.ReclaimInterval = defaultReclaimInterval
+	}
+	if cfg.MaxDeliveries <= 0 {
+		cfg.MaxDeliveries = defaultMaxDeliveries
+	}
+
+	log.Info().Msgf("init redis stream consumer success : STREAM = %v, CONSUMER = %s", streams, cfg.ConsumerName)
+	return &Consumer{
+		client:  client,
+		cfg:     cfg,
+		streams: streams,
+	}
+}
+
+func (s *Consumer) OnEvent(handler kafka.OnEventHandler) {
+	s.mu.Lock()
+	if handler != nil {
+		s.handler = handler
+	}
+	s.mu.Unlock()
+}
+
+func (s *Consumer) Start(ctx context.Context) error {
+	s.mu.Lock()
+	if s.started {
+		s.mu.Unlock()
+		return kafka.ErrAlreadyStarted
+	}
+	if s.handler == nil {
+		s.mu.Unlock()
+		return kafka.ErrNilEventHandler
+	}
+	if s.cfg.GroupID == "" {
+		s.mu.Unlock()
+		return ErrEmptyGroupID
+	}
+	ctx, s.cancel = context.WithCancel(ctx)
+	s.done = make(chan struct{})
+	s.started = true
+	s.mu.Unlock()
+
+	defer close(s.done)
+
+	for _, stream := range s.streams {
+		err := s.client.XGroupCreateMkStream(ctx, stream, s.cfg.GroupID, startFromBeginning).Err()
+		if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+			// not started, Start can be called again
+			s.mu.Lock()
+			s.cancel()
+			s.started, s.cancel = false, nil
+			s.mu.Unlock()
+			return fmt.Errorf("create group: %w", err)
+		}
+	}
+
+	// XREADGROUP expects all stream names followed by their ids
+	streamArgs := make([]string, 0, len(s.streams)*2)
+	streamArgs = append(streamArgs, s.streams...)
+	for range s.streams {
+		streamArgs = append(streamArgs, ">")
+	}
+
+	lastReclaim := time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		default:
+		}
+
+		if time.Since(lastReclaim) >= s.cfg.ReclaimInterval {
+			s.reclaim(ctx)
+			lastReclaim = time.Now()
+		}
+
+		res, err := s.client.XReadGroup(ctx, &redis.XReadGroupArgs{
+			Group:    s.cfg.GroupID,
+			Consumer: s.cfg.ConsumerName,
+			Streams:  streamArgs,
+			Count:    s.cfg.BatchSize,
+			Block:    s.cfg.BlockTime,
+		}).Result()
+		if err != nil {
+			if errors.Is(err, redis.Nil) || ctx.Err() != nil {
+				continue
+			}
+			logger.GetLogger().Warn().Err(err).Msg("redis stream read message failed")
+			time.Sleep(time.Second)
+			continue
+		}
+
+		for _, stream := range res {
+			for _, msg := range stream.Messages {
+				s.handle(ctx, stream.Stream, msg)
+			}
+		}
+	}
+}
+
+// reclaim takes over messages pending longer than ReclaimMinIdle, whichever consumer owned them.
+func (s *Consumer) reclaim(ctx context.Context) {
+	log := logger.GetLogger()
+
+	for _, stream := range s.streams {
+		start := startFromBeginning
+		for {
+			msgs, next, err := s.client.XAutoClaim(ctx, &redis.XAutoClaimArgs{
+				Stream:   stream,
+				Group:    s.cfg.GroupID,
+				Consumer: s.cfg.ConsumerName,
+				MinIdle:  s.cfg.ReclaimMinIdle,
+				Start:    start,
+				Count:    s.cfg.BatchSize,
+			}).Result()
+			if err != nil {
+				log.Warn().Err(err).Msgf("redis stream reclaim failed: stream=%s", stream)
+				break
+			}
+
+			for _, msg := range msgs {
+				if s.deadLetterIfExhausted(ctx, stream, msg) {
+					continue
+				}
+				log.Info().Msgf("redis stream reclaim message: stream=%s, id=%s", stream, msg.ID)
+				s.handle(ctx, stream, msg)
+			}
+
+			if next == "" || next == "0-0" {
+				break
+			}
+			start = next
+		}
+	}
+}
+
+// deadLetterIfExhausted moves msg to the dead letter stream when it was delivered more
+// than MaxDeliveries times, the claim that returned it included.
+func (s *Consumer) deadLetterIfExhausted(ctx context.Context, stream string, msg redis.XMessage) bool {
+	log := logger.GetLogger()
+
+	pending, err := s.client.XPendingExt(ctx, &redis.XPendingExtArgs{
+		Stream: stream,
+		Group:  s.cfg.GroupID,
+		Start:  msg.ID,
+		End:    msg.ID,
+		Count:  1,
+	}).Result()
+	if err != nil || len(pending) == 0 {
+		if err != nil {
+			log.Warn().Err(err).Msgf("redis stream get delivery count failed: stream=%s, id=%s", stream, msg.ID)
+		}
+		return false
+	}
+
+	deliveries := pending[0].RetryCount
+	if deliveries <= s.cfg.MaxDeliveries {
+		return false
+	}
+
+	values := make(map[string]interface{}, len(msg.Values)+3)
+	for field, value := range msg.Values {
+		values[field] = value
+	}
+	values[fieldStream] = stream
+	values[fieldID] = msg.ID
+	values[fieldDeliveries] = deliveries
+
+	dlq := stream + dlqStreamSuffix
+	ctx = context.WithoutCancel(ctx)
+	if err = s.client.XAdd(ctx, &redis.XAddArgs{Stream: dlq, Values: values}).Err(); err != nil {
+		log.Err(err).Msgf("redis stream move message to dlq failed: stream=%s, id=%s", stream, msg.ID)
+		return true
+	}
+
+	log.Error().Msgf("redis stream message moved to dlq stream %s: id=%s, deliveries=%d", dlq, msg.ID, deliveries)
+	s.ack(ctx, stream, msg.ID)
+	return true
+}
+
+func (s *Consumer) handle(ctx context.Context, stream string, msg redis.XMessage) {
+	log := logger.GetLogger()
+
+	// entries trimmed by MAXLEN while pending come back without values
+	if len(msg.Values) == 0 {
+		s.ack(context.WithoutCancel(ctx), stream, msg.ID)
+		return
+	}
+
+	key := fieldBytes(msg.Values, fieldKey)
+	value := fieldBytes(msg.Values, fieldValue)
+
+	traceInfoExisted := false
+	newCtx := context.Background()
+
+	if header := fieldBytes(msg.Values, utils.KeyTraceInfo); len(header) > 0 {
+		traceInfo := utils.TraceInfo{}
+		if err := json.Unmarshal(header, &traceInfo); err == nil {
+			newCtx = context.WithValue(newCtx, utils.KeyTraceInfo, traceInfo)
+			traceInfoExisted = true
+		}
+	}
+
+	if !traceInfoExisted {
+		// in-flight handlers are drained on shutdown, do not hand them a cancelled context
+		newCtx, _ = utils.NewContextWithRequestId(context.WithoutCancel(ctx))
+	}
+
+	if headers := messageHeaders(msg.Values); len(headers) > 0 {
+		newCtx = kafka.ContextWithHeaders(newCtx, headers)
+	}
+	newCtx = kafka.ContextWithTopic(newCtx, stream)
+
+	log = log.AddTraceInfoContextRequest(newCtx)
+	log.Info().
+		Str("stream", stream).
+		Str("id", msg.ID).
+		Str("value", string(value)).
+		Str("key", string(key)).
+		Msg("redis stream read message success")
+
+	if err := s.handler(newCtx, key, value); err != nil {
+		log.Err(err).Msg("redis stream handlers failed")
+		return
+	}
+
+	// Shutdown cancels ctx, the message handled meanwhile must still be acked
+	s.ack(context.WithoutCancel(ctx), stream, msg.ID)
+}
+
+func (s *Consumer) ack(ctx context.Context, stream, id string) {
+	if err := s.client.XAck(ctx, stream, s.cfg.GroupID, id).Err(); err != nil {
+		logger.GetLogger().Err(err).Msgf("redis stream ack failed: stream=%s, id=%s", stream, id)
+	}
+}
+
+// messageHeaders returns the headers the producer stored as prefixed fields.
+func messageHeaders(values map[string]interface{}) map[string]string {
+	var headers map[string]string
+	for field := range values {
+		if key, ok := strings.CutPrefix(field, fieldHeaderPrefix); ok {
+			if headers == nil {
+				headers = make(map[string]string)
+			}
+			headers[key] = string(fieldBytes(values, field))
+		}
+	}
+	return headers
+}
+
+func fieldBytes(values map[string]interface{}, field string) []byte {
+	switch v := values[field].(type) {
+	case string:
+		return []byte(v)
+	case []byte:
+		return v
+	default:
+		return nil
+	}
+}
+
+// Shutdown stops reading and waits for the message being handled, or until ctx is done.
+func (s *Consumer) Shutdown(ctx context.Context) {
+	s.mu.RLock()
+	cancel, done := s.cancel, s.done
+	s.mu.RUnlock()
+
+	if cancel == nil {
+		return
+	}
+	cancel()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		logger.GetLogger().Warn().Msg("redis stream consumer shutdown timeout")
+	}
+}
+
+func (s *Consumer) GetTopics() []string {
+	return s.streams
+}
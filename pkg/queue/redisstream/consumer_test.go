@@ -0,0 +1,193 @@
+package redisstream
+
+import (
+	"context"
+	logger "go-source/pkg/log"
+	"go-source/pkg/queue/kafka"
+	"go-source/pkg/utils"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	confluent "github.com/confluentinc/confluent-kafka-go/kafka"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testStream = "orders"
+
+func newTestConsumer(t *testing.T, client redis.UniversalClient, name string, handler func(ctx context.Context, key, value []byte) error) *Consumer {
+	consumer := NewConsumer(client, RedisStreamConfig{
+		GroupID:         "group",
+		ConsumerName:    name,
+		BlockTime:       10 * time.Millisecond,
+		ReclaimMinIdle:  20 * time.Millisecond,
+		ReclaimInterval: 20 * time.Millisecond,
+		MaxDeliveries:   2,
+	}, []string{testStream})
+	consumer.OnEvent(handler)
+
+	go func() {
+		_ = consumer.Start(context.Background())
+	}()
+	t.Cleanup(func() {
+		consumer.Shutdown(context.Background())
+	})
+	return consumer
+}
+
+func newTestClient(t *testing.T) redis.UniversalClient {
+	logger.InitLog("test")
+	client := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	t.Cleanup(func() {
+		_ = client.Close()
+	})
+	return client
+}
+
+func TestConsumer_Group(t *testing.T) {
+	client := newTestClient(t)
+	producer := NewProducer(client, RedisStreamConfig{}, testStream)
+
+	var (
+		received []string
+		mu       sync.Mutex
+	)
+	handler := func(ctx context.Context, key, value []byte) error {
+		mu.Lock()
+		received = append(received, string(value))
+		mu.Unlock()
+		return nil
+	}
+	newTestConsumer(t, client, "a", handler)
+	newTestConsumer(t, client, "b", handler)
+
+	for _, value := range []string{"1", "2", "3", "4"} {
+		require.NoError(t, producer.Publish(context.Background(), "key", value))
+	}
+
+	// each message goes to one consumer of the group, then is acked
+	assert.Eventually(t, func() bool {
+		pending, err := client.XPending(context.Background(), testStream, "group").Result()
+		mu.Lock()
+		defer mu.Unlock()
+		return err == nil && pending.Count == 0 && len(received) == 4
+	}, time.Second, 10*time.Millisecond)
+	mu.Lock()
+	assert.ElementsMatch(t, []string{"1", "2", "3", "4"}, received)
+	mu.Unlock()
+}
+
+func TestConsumer_StartAfterGroupCreateFailed(t *testing.T) {
+	client := newTestClient(t)
+	// a key of another type fails the group creation
+	require.NoError(t, client.Set(context.Background(), testStream, "value", 0).Err())
+
+	consumer := NewConsumer(client, RedisStreamConfig{GroupID: "group", ConsumerName: "a", BlockTime: 10 * time.Millisecond}, []string{testStream})
+	consumer.OnEvent(func(ctx context.Context, key, value []byte) error { return nil })
+	require.Error(t, consumer.Start(context.Background()))
+
+	require.NoError(t, client.Del(context.Background(), testStream).Err())
+	started := make(chan error, 1)
+	go func() {
+		started <- consumer.Start(context.Background())
+	}()
+
+	assert.Eventually(t, func() bool {
+		groups, err := client.XInfoGroups(context.Background(), testStream).Result()
+		return err == nil && len(groups) == 1
+	}, time.Second, 10*time.Millisecond)
+	consumer.Shutdown(context.Background())
+	assert.NoError(t, <-started)
+}
+
+func TestConsumer_ReclaimAndDLQ(t *testing.T) {
+	client := newTestClient(t)
+	producer := NewProducer(client, RedisStreamConfig{}, testStream)
+
+	var flakyCalls, poisonCalls atomic.Int32
+	newTestConsumer(t, client, "a", func(ctx context.Context, key, value []byte) error {
+		if string(value) == "poison" {
+			poisonCalls.Add(1)
+			return assert.AnError
+		}
+		// fails once, the reclaimed delivery succeeds
+		if flakyCalls.Add(1) == 1 {
+			return assert.AnError
+		}
+		return nil
+	})
+
+	require.NoError(t, producer.Publish(context.Background(), "key", "flaky"))
+	require.NoError(t, producer.Publish(context.Background(), "key", "poison"))
+
+	var dlq []redis.XMessage
+	assert.Eventually(t, func() bool {
+		var err error
+		dlq, err = client.XRange(context.Background(), testStream+dlqStreamSuffix, "-", "+").Result()
+		return err == nil && len(dlq) == 1
+	}, 2*time.Second, 10*time.Millisecond)
+
+	assert.Equal(t, "poison", dlq[0].Values[fieldValue])
+	assert.Equal(t, testStream, dlq[0].Values[fieldStream])
+	assert.Equal(t, int32(2), poisonCalls.Load())
+	assert.Equal(t, int32(2), flakyCalls.Load())
+
+	pending, err := client.XPending(context.Background(), testStream, "group").Result()
+	require.NoError(t, err)
+	assert.Zero(t, pending.Count)
+}
+
+func TestConsumer_TracePropagation(t *testing.T) {
+	client := newTestClient(t)
+	producer := NewProducer(client, RedisStreamConfig{}, testStream)
+
+	traces := make(chan *utils.TraceInfo, 1)
+	newTestConsumer(t, client, "a", func(ctx context.Context, key, value []byte) error {
+		traces <- utils.GetRequestIdByContext(ctx)
+		return nil
+	})
+
+	ctx := context.WithValue(context.Background(), utils.KeyTraceInfo, utils.TraceInfo{RequestID: "req-1"})
+	require.NoError(t, producer.Publish(ctx, "key", "value"))
+
+	select {
+	case trace := <-traces:
+		require.NotNil(t, trace)
+		assert.Equal(t, "req-1", trace.RequestID)
+	case <-time.After(time.Second):
+		t.Fatal("message not received")
+	}
+}
+
+func TestConsumer_HeadersRoundTrip(t *testing.T) {
+	client := newTestClient(t)
+	producer := NewProducer(client, RedisStreamConfig{}, testStream)
+
+	headers := make(chan map[string]string, 2)
+	newTestConsumer(t, client, "a", func(ctx context.Context, key, value []byte) error {
+		headers <- kafka.HeadersFromContext(ctx)
+		return nil
+	})
+
+	ctx := kafka.ContextWithHeaders(context.Background(), map[string]string{"tenant": "t1"})
+	require.NoError(t, producer.Publish(ctx, "key", "value"))
+	topic := testStream
+	require.NoError(t, producer.PublishMessage(context.Background(), &confluent.Message{
+		TopicPartition: confluent.TopicPartition{Topic: &topic},
+		Value:          []byte("value"),
+		Headers:        []confluent.Header{{Key: "tenant", Value: []byte("t2")}},
+	}))
+
+	for _, want := range []string{"t1", "t2"} {
+		select {
+		case got := <-headers:
+			assert.Equal(t, map[string]string{"tenant": want}, got)
+		case <-time.After(time.Second):
+			t.Fatal("message not received")
+		}
+	}
+}
@@ -0,0 +1,200 @@
+package redisstream
+
+import (
+	"context"
+	"encoding/json"
+	"go-source/pkg/queue/kafka"
+	"go-source/pkg/utils"
+
+	confluent "github.com/confluentinc/confluent-kafka-go/kafka"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	fieldKey   = "key"
+	fieldValue = "value"
+	// fieldHeaderPrefix prefixes the fields holding the message headers
+	fieldHeaderPrefix = "header:"
+)
+
+var _ kafka.ProducerInterface = (*Producer)(nil)
+
+// Producer appends to a stream the way kafka.Producer publishes to a topic, so it
+// can replace it. Streams have no partitions, the partition arguments are ignored,
+// and XADD returns once stored, so asynchronous publishes are synchronous.
+type Producer struct {
+	client redis.UniversalClient
+	stream string
+	maxLen int64
+}
+
+func NewProducer(client redis.UniversalClient, cfg RedisStreamConfig, stream string) *Producer {
+	return &Producer{
+		client: client,
+		stream: stream,
+		maxLen: cfg.MaxLen,
+	}
+}
+
+func marshal(val interface{}) ([]byte, error) {
+	switch v := val.(type) {
+	case string:
+		return []byte(v), nil
+	case []byte:
+		return v, nil
+	default:
+		return json.Marshal(val)
+	}
+}
+
+func (s *Producer) Publish(ctx context.Context, key, value interface{}) error {
+	return s.PublishWithStream(ctx, s.stream, key, value)
+}
+
+func (s *Producer) PublishSync(ctx context.Context, key, value interface{}) error {
+	return s.Publish(ctx, key, value)
+}
+
+// PublishAsync publishes then calls cb with the message, its offset is not set.
+func (s *Producer) PublishAsync(ctx context.Context, key, value interface{}, cb kafka.DeliveryCallback) error {
+	values, err := newValues(ctx, key, value)
+	if err != nil {
+		return err
+	}
+
+	err = s.add(ctx, s.stream, values)
+	if cb != nil {
+		cb(deliveryReport(s.stream, values), err)
+	}
+	return err
+}
+
+func (s *Producer) PublishWithPartition(ctx context.Context, key, value interface{}, _ int32) error {
+	return s.Publish(ctx, key, value)
+}
+
+func (s *Producer) PublishWithPartitionCRC32(ctx context.Context, key, value interface{}) error {
+	return s.Publish(ctx, key, value)
+}
+
+func (s *Producer) PublishWithTopic(ctx context.Context, topic string, key, value interface{}) error {
+	return s.PublishWithStream(ctx, topic, key, value)
+}
+
+func (s *Producer) PublishWithStream(ctx context.Context, stream string, key, value interface{}) error {
+	values, err := newValues(ctx, key, value)
+	if err != nil {
+		return err
+	}
+	return s.add(ctx, stream, values)
+}
+
+// PublishBytes publishes key and value as they are, with the headers of ctx only.
+func (s *Producer) PublishBytes(ctx context.Context, key, value []byte) error {
+	values := map[string]interface{}{
+		fieldKey:   key,
+		fieldValue: value,
+	}
+	for k, v := range kafka.HeadersFromContext(ctx) {
+		values[fieldHeaderPrefix+k] = v
+	}
+	return s.add(ctx, s.stream, values)
+}
+
+// PublishMessage publishes msg to the stream named by its topic, or to the stream of
+// the producer when it has none. The headers become fields.
+func (s *Producer) PublishMessage(ctx context.Context, msg *confluent.Message) error {
+	stream := s.stream
+	if msg.TopicPartition.Topic != nil && *msg.TopicPartition.Topic != "" {
+		stream = *msg.TopicPartition.Topic
+	}
+
+	values := map[string]interface{}{
+		fieldKey:   msg.Key,
+		fieldValue: msg.Value,
+	}
+	for _, h := range msg.Headers {
+		if h.Key == utils.KeyTraceInfo {
+			if len(h.Value) > 0 {
+				values[utils.KeyTraceInfo] = h.Value
+			}
+			continue
+		}
+		values[fieldHeaderPrefix+h.Key] = h.Value
+	}
+	return s.add(ctx, stream, values)
+}
+
+func (s *Producer) PublishMessageSync(ctx context.Context, msg *confluent.Message) error {
+	return s.PublishMessage(ctx, msg)
+}
+
+// newValues marshals key and value into stream fields, with the trace info and the
+// headers of ctx.
+func newValues(ctx context.Context, key, value interface{}) (map[string]interface{}, error) {
+	keyData, err := marshal(key)
+	if err != nil {
+		return nil, err
+	}
+	valueData, err := marshal(value)
+	if err != nil {
+		return nil, err
+	}
+
+	values := map[string]interface{}{
+		fieldKey:   keyData,
+		fieldValue: valueData,
+	}
+
+	traceInfo := utils.GetRequestIdByContext(ctx)
+	if traceInfo != nil {
+		header, err := marshal(traceInfo)
+		if err != nil {
+			return nil, err
+		}
+		values[utils.KeyTraceInfo] = header
+	}
+
+	for k, v := range kafka.HeadersFromContext(ctx) {
+		values[fieldHeaderPrefix+k] = v
+	}
+
+	return values, nil
+}
+
+func (s *Producer) add(ctx context.Context, stream string, values map[string]interface{}) error {
+	args := &redis.XAddArgs{
+		Stream: stream,
+		Values: values,
+	}
+
+	// trim with "~" so Redis only evicts whole macro nodes, which is much cheaper
+	if s.maxLen > 0 {
+		args.MaxLen = s.maxLen
+		args.Approx = true
+	}
+
+	return s.client.XAdd(ctx, args).Err()
+}
+
+func deliveryReport(stream string, values map[string]interface{}) *confluent.Message {
+	key, _ := values[fieldKey].([]byte)
+	value, _ := values[fieldValue].([]byte)
+	return &confluent.Message{
+		Key:   key,
+		Value: value,
+		TopicPartition: confluent.TopicPartition{
+			Topic:     &stream,
+			Partition: confluent.PartitionAny,
+		},
+	}
+}
+
+func (s *Producer) GetTopicName() string {
+	return s.stream
+}
+
+// Close does nothing, the redis client is owned by the caller.
+func (s *Producer) Close(_ context.Context) error {
+	return nil
+}
@@ -0,0 +1,38 @@
+package redisstream
+
+import (
+	"context"
+	"go-source/pkg/queue/kafka"
+	"testing"
+
+	confluent "github.com/confluentinc/confluent-kafka-go/kafka"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProducer_Headers(t *testing.T) {
+	client := newTestClient(t)
+	var producer kafka.ProducerInterface = NewProducer(client, RedisStreamConfig{}, testStream)
+
+	ctx := kafka.ContextWithHeaders(context.Background(), map[string]string{"tenant": "a"})
+	require.NoError(t, producer.PublishWithPartition(ctx, "key", "1", 3))
+
+	other := "other"
+	require.NoError(t, producer.PublishMessage(context.Background(), &confluent.Message{
+		TopicPartition: confluent.TopicPartition{Topic: &other},
+		Key:            []byte("key"),
+		Value:          []byte("2"),
+		Headers:        []confluent.Header{{Key: "tenant", Value: []byte("b")}},
+	}))
+
+	msgs, err := client.XRange(context.Background(), testStream, "-", "+").Result()
+	require.NoError(t, err)
+	require.Len(t, msgs, 1)
+	assert.Equal(t, "1", msgs[0].Values[fieldValue])
+	assert.Equal(t, "a", msgs[0].Values[fieldHeaderPrefix+"tenant"])
+
+	msgs, err = client.XRange(context.Background(), other, "-", "+").Result()
+	require.NoError(t, err)
+	require.Len(t, msgs, 1)
+	assert.Equal(t, "b", msgs[0].Values[fieldHeaderPrefix+"tenant"])
+}
@@ -0,0 +1,71 @@
+package ratelimit
+
+import (
+	"go-source/pkg/utils"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// KeyExtractor resolves the rate limit key of a request.
+// An empty key means the policy does not apply to the request.
+type KeyExtractor func(c echo.Context) string
+
+// KeyByIP uses the real client IP.
+func KeyByIP(c echo.Context) string {
+	return c.RealIP()
+}
+
+// KeyBySub uses the JWT subject set in the context by the authorization middleware.
+// The token is never read here, unverified claims would let a client pick its key,
+// so the key is empty before authorization and a KeyByIP policy applies instead.
+func KeyBySub(c echo.Context) string {
+	sub, _ := c.Request().Context().Value(utils.JwtSub).(string)
+	return sub
+}
+
+// KeyByMeProfile uses the X-Me-Profile value set by the authorization middleware,
+// falling back to the request header.
+func KeyByMeProfile(c echo.Context) string {
+	if me, ok := c.Request().Context().Value(utils.HeaderXMeProfile).(string); ok && me != "" {
+		return me
+	}
+	return c.Request().Header.Get(utils.HeaderXMeProfile)
+}
+
+// KeyByRoute uses the method and the registered route path, e.g. "GET /v1/users/:id".
+func KeyByRoute(c echo.Context) string {
+	path := c.Path()
+	if path == "" {
+		path = c.Request().URL.Path
+	}
+	return c.Request().Method + " " + path
+}
+
+// KeyByRegion uses the X-Client-Region header.
+func KeyByRegion(c echo.Context) string {
+	return c.Request().Header.Get(utils.KeyRegion)
+}
+
+// KeyByValue uses the string stored in the request context under key-limit,
+// which is what RateLimit expects upstream middlewares to set.
+func KeyByValue(c echo.Context) string {
+	value, _ := c.Request().Context().Value(utils.KeyRateLimit).(string)
+	return value
+}
+
+// ComposeKeys joins several extractors, e.g. route + sub for a per-user per-route limit.
+// The key is empty when any part is empty.
+func ComposeKeys(extractors ...KeyExtractor) KeyExtractor {
+	return func(c echo.Context) string {
+		parts := make([]string, 0, len(extractors))
+		for _, extractor := range extractors {
+			part := extractor(c)
+			if part == "" {
+				return ""
+			}
+			parts = append(parts, part)
+		}
+		return strings.Join(parts, ":")
+	}
+}
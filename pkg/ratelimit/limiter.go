@@ -0,0 +1,133 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	logger "go-source/pkg/log"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	AlgorithmSlidingWindow = "sliding_window"
+	AlgorithmTokenBucket   = "token_bucket"
+
+	keyPrefix = "rate_limit:"
+)
+
+var (
+	ErrInvalidLimit     = errors.New("rate limit: rate and period must be greater than zero")
+	ErrInvalidAlgorithm = errors.New("rate limit: algorithm invalid")
+	ErrNilRedisClient   = errors.New("rate limit: redis client is nil")
+)
+
+// Limit describes how many requests are allowed per period.
+// Burst is only used by the token bucket algorithm, it defaults to Rate.
+type Limit struct {
+	Rate   int
+	Burst  int
+	Period time.Duration
+}
+
+func PerSecond(rate int) Limit {
+	return Limit{Rate: rate, Burst: rate, Period: time.Second}
+}
+
+func PerMinute(rate int) Limit {
+	return Limit{Rate: rate, Burst: rate, Period: time.Minute}
+}
+
+func PerHour(rate int) Limit {
+	return Limit{Rate: rate, Burst: rate, Period: time.Hour}
+}
+
+func (l Limit) validate() error {
+	if l.Rate <= 0 || l.Period <= 0 {
+		return ErrInvalidLimit
+	}
+	return nil
+}
+
+func (l Limit) burst() int {
+	if l.Burst <= 0 {
+		return l.Rate
+	}
+	return l.Burst
+}
+
+// Result is the outcome of a single Allow call.
+type Result struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	RetryAfter time.Duration
+	ResetAfter time.Duration
+}
+
+type LimiterInterface interface {
+	Allow(ctx context.Context, key string, limit Limit) (*Result, error)
+}
+
+// Limiter evaluates limits against Redis and falls back to an in-memory
+// limiter of the same algorithm when Redis is missing or unreachable.
+type Limiter struct {
+	algorithm string
+	redis     LimiterInterface
+	local     LimiterInterface
+}
+
+func NewLimiter(client redis.UniversalClient, algorithm string) (*Limiter, error) {
+	var (
+		redisLimiter LimiterInterface
+		localLimiter LimiterInterface
+	)
+
+	switch algorithm {
+	case AlgorithmSlidingWindow:
+		if client != nil {
+			redisLimiter = newRedisSlidingWindow(client)
+		}
+		localLimiter = newLocalSlidingWindow()
+
+	case AlgorithmTokenBucket:
+		if client != nil {
+			redisLimiter = newRedisTokenBucket(client)
+		}
+		localLimiter = newLocalTokenBucket()
+
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrInvalidAlgorithm, algorithm)
+	}
+
+	return &Limiter{
+		algorithm: algorithm,
+		redis:     redisLimiter,
+		local:     localLimiter,
+	}, nil
+}
+
+func (l *Limiter) Allow(ctx context.Context, key string, limit Limit) (*Result, error) {
+	if err := limit.validate(); err != nil {
+		return nil, err
+	}
+
+	key = keyPrefix + key
+
+	if l.redis != nil {
+		res, err := l.redis.Allow(ctx, key, limit)
+		if err == nil {
+			return res, nil
+		}
+
+		log := logger.GetLogger().AddTraceInfoContextRequest(ctx)
+		log.Warn().Err(err).Msgf("rate limit redis failed, fallback to local: algorithm=%s", l.algorithm)
+	}
+
+	return l.local.Allow(ctx, key, limit)
+}
+
+func (l *Limiter) GetAlgorithm() string {
+	return l.algorithm
+}
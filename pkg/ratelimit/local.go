@@ -0,0 +1,159 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// sweepInterval controls how often expired in-memory entries are removed.
+const sweepInterval = time.Minute
+
+type window struct {
+	hits   []time.Time
+	period time.Duration
+}
+
+type localSlidingWindow struct {
+	mu        sync.Mutex
+	entries   map[string]*window
+	lastSweep time.Time
+}
+
+func newLocalSlidingWindow() *localSlidingWindow {
+	return &localSlidingWindow{
+		entries:   make(map[string]*window),
+		lastSweep: time.Now(),
+	}
+}
+
+func (l *localSlidingWindow) Allow(_ context.Context, key string, limit Limit) (*Result, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.sweep(now)
+
+	w, ok := l.entries[key]
+	if !ok {
+		w = &window{}
+		l.entries[key] = w
+	}
+	w.period = limit.Period
+
+	// drop timestamps outside the window
+	hits := w.hits
+	start := now.Add(-limit.Period)
+	i := 0
+	for i < len(hits) && !hits[i].After(start) {
+		i++
+	}
+	hits = hits[i:]
+
+	allowed := len(hits) < limit.Rate
+	if allowed {
+		hits = append(hits, now)
+	}
+	w.hits = hits
+
+	reset := limit.Period
+	if len(hits) > 0 {
+		reset = hits[0].Add(limit.Period).Sub(now)
+	}
+
+	res := &Result{
+		Allowed:    allowed,
+		Limit:      limit.Rate,
+		Remaining:  limit.Rate - len(hits),
+		ResetAfter: reset,
+	}
+	if !allowed {
+		res.RetryAfter = reset
+	}
+
+	return res, nil
+}
+
+func (l *localSlidingWindow) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < sweepInterval {
+		return
+	}
+	l.lastSweep = now
+
+	for key, w := range l.entries {
+		if len(w.hits) == 0 || now.Sub(w.hits[len(w.hits)-1]) > w.period {
+			delete(l.entries, key)
+		}
+	}
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+	burst  float64
+	rate   float64
+}
+
+type localTokenBucket struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+func newLocalTokenBucket() *localTokenBucket {
+	return &localTokenBucket{
+		buckets:   make(map[string]*bucket),
+		lastSweep: time.Now(),
+	}
+}
+
+func (l *localTokenBucket) Allow(_ context.Context, key string, limit Limit) (*Result, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	burst := float64(limit.burst())
+	// tokens refilled per nanosecond
+	rate := float64(limit.Rate) / float64(limit.Period)
+
+	l.sweep(now)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: burst, last: now}
+		l.buckets[key] = b
+	}
+	b.burst = burst
+	b.rate = rate
+
+	b.tokens = math.Min(burst, b.tokens+float64(now.Sub(b.last))*rate)
+	b.last = now
+
+	res := &Result{Limit: limit.burst()}
+	if b.tokens >= 1 {
+		b.tokens--
+		res.Allowed = true
+	} else {
+		res.RetryAfter = time.Duration(math.Ceil((1 - b.tokens) / rate))
+	}
+
+	res.Remaining = int(math.Floor(b.tokens))
+	res.ResetAfter = time.Duration(math.Ceil((burst - b.tokens) / rate))
+
+	return res, nil
+}
+
+func (l *localTokenBucket) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < sweepInterval {
+		return
+	}
+	l.lastSweep = now
+
+	// a bucket that would be full again carries no state
+	for key, b := range l.buckets {
+		if b.tokens+float64(now.Sub(b.last))*b.rate >= b.burst {
+			delete(l.buckets, key)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLocalSlidingWindow_Allow(t *testing.T) {
+	limiter := newLocalSlidingWindow()
+	limit := Limit{Rate: 3, Period: time.Minute}
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		res, err := limiter.Allow(ctx, "key", limit)
+		assert.NoError(t, err)
+		assert.True(t, res.Allowed)
+		assert.Equal(t, 2-i, res.Remaining)
+	}
+
+	res, err := limiter.Allow(ctx, "key", limit)
+	assert.NoError(t, err)
+	assert.False(t, res.Allowed)
+	assert.Equal(t, 0, res.Remaining)
+	assert.True(t, res.RetryAfter > 0 && res.RetryAfter <= time.Minute)
+
+	res, err = limiter.Allow(ctx, "other", limit)
+	assert.NoError(t, err)
+	assert.True(t, res.Allowed)
+}
+
+func TestLocalTokenBucket_Allow(t *testing.T) {
+	limiter := newLocalTokenBucket()
+	limit := Limit{Rate: 1, Burst: 2, Period: 50 * time.Millisecond}
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		res, err := limiter.Allow(ctx, "key", limit)
+		assert.NoError(t, err)
+		assert.True(t, res.Allowed)
+	}
+
+	res, err := limiter.Allow(ctx, "key", limit)
+	assert.NoError(t, err)
+	assert.False(t, res.Allowed)
+	assert.True(t, res.RetryAfter > 0)
+
+	time.Sleep(res.RetryAfter + 5*time.Millisecond)
+
+	res, err = limiter.Allow(ctx, "key", limit)
+	assert.NoError(t, err)
+	assert.True(t, res.Allowed)
+}
+
+func TestLimiter_FallbackToLocal(t *testing.T) {
+	limiter, err := NewLimiter(nil, AlgorithmSlidingWindow)
+	assert.NoError(t, err)
+
+	res, err := limiter.Allow(context.Background(), "key", PerSecond(1))
+	assert.NoError(t, err)
+	assert.True(t, res.Allowed)
+
+	_, err = limiter.Allow(context.Background(), "key", Limit{})
+	assert.ErrorIs(t, err, ErrInvalidLimit)
+
+	_, err = NewLimiter(nil, "unknown")
+	assert.ErrorIs(t, err, ErrInvalidAlgorithm)
+}
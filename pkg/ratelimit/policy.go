@@ -0,0 +1,41 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Policy is one limit applied to requests sharing the same key.
+// Several policies can be stacked on a route, e.g. 10 req/s per IP and 1000 req/h per sub.
+type Policy struct {
+	Name    string
+	Key     KeyExtractor
+	Limit   Limit
+	Limiter LimiterInterface
+}
+
+func NewPolicy(name string, key KeyExtractor, limit Limit, limiter LimiterInterface) Policy {
+	return Policy{
+		Name:    name,
+		Key:     key,
+		Limit:   limit,
+		Limiter: limiter,
+	}
+}
+
+// Allow evaluates the policy for the request. It returns nil result when the
+// key extractor yields no key, so the policy is skipped.
+func (p Policy) Allow(ctx context.Context, c echo.Context) (*Result, error) {
+	if p.Key == nil || p.Limiter == nil {
+		return nil, fmt.Errorf("rate limit: policy %s missing key or limiter", p.Name)
+	}
+
+	key := p.Key(c)
+	if key == "" {
+		return nil, nil
+	}
+
+	return p.Limiter.Allow(ctx, p.Name+":"+key, p.Limit)
+}
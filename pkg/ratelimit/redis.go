@@ -0,0 +1,137 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"go-source/pkg/utils"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// slidingWindowScript keeps one sorted set entry per accepted request,
+// scored by its timestamp in milliseconds.
+// Returns {allowed, remaining, retry_after_ms, reset_after_ms}.
+var slidingWindowScript = redis.NewScript(`
+local key = KEYS[1]
+local now = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local limit = tonumber(ARGV[3])
+local member = ARGV[4]
+
+redis.call('ZREMRANGEBYSCORE', key, 0, now - window)
+local count = redis.call('ZCARD', key)
+
+local allowed = 0
+if count < limit then
+	redis.call('ZADD', key, now, member)
+	redis.call('PEXPIRE', key, window)
+	count = count + 1
+	allowed = 1
+end
+
+local reset = window
+local oldest = redis.call('ZRANGE', key, 0, 0, 'WITHSCORES')
+if oldest[2] then
+	reset = window - (now - tonumber(oldest[2]))
+end
+
+local retry = 0
+if allowed == 0 then
+	retry = reset
+end
+
+return {allowed, limit - count, retry, reset}
+`)
+
+// tokenBucketScript stores the remaining tokens and the last refill time in a hash.
+// Returns {allowed, remaining, retry_after_ms, reset_after_ms}.
+var tokenBucketScript = redis.NewScript(`
+local key = KEYS[1]
+local now = tonumber(ARGV[1])
+local rate = tonumber(ARGV[2])
+local period = tonumber(ARGV[3])
+local burst = tonumber(ARGV[4])
+
+local data = redis.call('HMGET', key, 'tokens', 'ts')
+local tokens = tonumber(data[1])
+local ts = tonumber(data[2])
+if tokens == nil or ts == nil then
+	tokens = burst
+	ts = now
+end
+
+local elapsed = math.max(0, now - ts)
+tokens = math.min(burst, tokens + elapsed * rate / period)
+
+local allowed = 0
+local retry = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+else
+	retry = math.ceil((1 - tokens) * period / rate)
+end
+
+local reset = math.ceil((burst - tokens) * period / rate)
+
+redis.call('HSET', key, 'tokens', tostring(tokens), 'ts', now)
+redis.call('PEXPIRE', key, math.max(1, math.ceil(burst * period / rate)))
+
+return {allowed, math.floor(tokens), retry, reset}
+`)
+
+type redisSlidingWindow struct {
+	client redis.UniversalClient
+}
+
+func newRedisSlidingWindow(client redis.UniversalClient) *redisSlidingWindow {
+	return &redisSlidingWindow{client: client}
+}
+
+func (r *redisSlidingWindow) Allow(ctx context.Context, key string, limit Limit) (*Result, error) {
+	now := time.Now().UnixMilli()
+	member := fmt.Sprintf("%d-%s", now, utils.RandString())
+
+	values, err := slidingWindowScript.Run(ctx, r.client, []string{key},
+		now, limit.Period.Milliseconds(), limit.Rate, member).Int64Slice()
+	if err != nil {
+		return nil, err
+	}
+
+	return parseScriptResult(values, limit.Rate)
+}
+
+type redisTokenBucket struct {
+	client redis.UniversalClient
+}
+
+func newRedisTokenBucket(client redis.UniversalClient) *redisTokenBucket {
+	return &redisTokenBucket{client: client}
+}
+
+func (r *redisTokenBucket) Allow(ctx context.Context, key string, limit Limit) (*Result, error) {
+	now := time.Now().UnixMilli()
+
+	values, err := tokenBucketScript.Run(ctx, r.client, []string{key},
+		now, limit.Rate, limit.Period.Milliseconds(), limit.burst()).Int64Slice()
+	if err != nil {
+		return nil, err
+	}
+
+	return parseScriptResult(values, limit.burst())
+}
+
+func parseScriptResult(values []int64, limit int) (*Result, error) {
+	if len(values) != 4 {
+		return nil, fmt.Errorf("rate limit: unexpected script result: %v", values)
+	}
+
+	return &Result{
+		Allowed:    values[0] == 1,
+		Limit:      limit,
+		Remaining:  int(values[1]),
+		RetryAfter: time.Duration(values[2]) * time.Millisecond,
+		ResetAfter: time.Duration(values[3]) * time.Millisecond,
+	}, nil
+}
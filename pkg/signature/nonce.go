@@ -0,0 +1,37 @@
+package signature
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const nonceKeyPrefix = "signature:nonce:"
+
+var ErrNilRedisClient = errors.New("signature: redis client is nil")
+
+// NonceStoreInterface remembers the nonces already used by a client.
+type NonceStoreInterface interface {
+	// Use records nonce and returns false when it was already used within ttl.
+	Use(ctx context.Context, clientID, nonce string, ttl time.Duration) (bool, error)
+}
+
+var _ NonceStoreInterface = (*RedisNonceStore)(nil)
+
+type RedisNonceStore struct {
+	client redis.UniversalClient
+}
+
+func NewRedisNonceStore(client redis.UniversalClient) *RedisNonceStore {
+	return &RedisNonceStore{client: client}
+}
+
+func (s *RedisNonceStore) Use(ctx context.Context, clientID, nonce string, ttl time.Duration) (bool, error) {
+	if s.client == nil {
+		return false, ErrNilRedisClient
+	}
+
+	return s.client.SetNX(ctx, nonceKeyPrefix+clientID+":"+nonce, time.Now().Unix(), ttl).Result()
+}
@@ -0,0 +1,155 @@
+package signature
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"go-source/pkg/utils"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	AlgEdDSA = "EdDSA"
+	// AlgRSA is RSASSA-PKCS1-v1_5 with SHA-256.
+	AlgRSA = "RSA"
+)
+
+var (
+	ErrClientNotFound   = errors.New("signature: client not found")
+	ErrSignatureInvalid = errors.New("signature invalid")
+)
+
+// ClientKey is the public key a client signs its requests with.
+type ClientKey struct {
+	Alg string `json:"alg"`
+	// PublicKey is the hex Ed25519 key for EdDSA, the PEM key for RSA
+	PublicKey string `json:"publicKey"`
+}
+
+// Validate checks the key can be used to verify signatures.
+func (k ClientKey) Validate() error {
+	switch k.Alg {
+	case AlgEdDSA:
+		return utils.CheckPublicKeyEdDSA(k.PublicKey)
+	case AlgRSA:
+		_, err := jwt.ParseRSAPublicKeyFromPEM([]byte(k.PublicKey))
+		return err
+	default:
+		return fmt.Errorf("signature: alg invalid: %s", k.Alg)
+	}
+}
+
+// Verify verifies the hex signature sigHex of message.
+func (k ClientKey) Verify(message, sigHex string) error {
+	switch k.Alg {
+	case AlgEdDSA:
+		// ed25519.Verify panics on a key of the wrong size
+		if err := utils.CheckPublicKeyEdDSA(k.PublicKey); err != nil {
+			return fmt.Errorf("signature: public key invalid: %w", err)
+		}
+
+		ok, err := utils.EdDSAVerifySignature(message, sigHex, k.PublicKey)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrSignatureInvalid, err)
+		}
+		if !ok {
+			return ErrSignatureInvalid
+		}
+		return nil
+
+	case AlgRSA:
+		pubKey, err := jwt.ParseRSAPublicKeyFromPEM([]byte(k.PublicKey))
+		if err != nil {
+			return err
+		}
+
+		sig, err := hex.DecodeString(sigHex)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrSignatureInvalid, err)
+		}
+
+		hashed := sha256.Sum256([]byte(message))
+		if err := rsa.VerifyPKCS1v15(pubKey, crypto.SHA256, hashed[:], sig); err != nil {
+			return ErrSignatureInvalid
+		}
+		return nil
+
+	default:
+		return fmt.Errorf("signature: alg invalid: %s", k.Alg)
+	}
+}
+
+// KeyStoreInterface returns the key of a client.
+type KeyStoreInterface interface {
+	ClientKey(ctx context.Context, clientID string) (*ClientKey, error)
+}
+
+type StaticKeyStore struct {
+	keys map[string]ClientKey
+}
+
+// NewStaticKeyStore serves the keys by client ID, they are validated upfront.
+func NewStaticKeyStore(keys map[string]ClientKey) (*StaticKeyStore, error) {
+	for clientID, key := range keys {
+		if err := key.Validate(); err != nil {
+			return nil, fmt.Errorf("client %s: %w", clientID, err)
+		}
+	}
+
+	return &StaticKeyStore{keys: keys}, nil
+}
+
+func (s *StaticKeyStore) ClientKey(_ context.Context, clientID string) (*ClientKey, error) {
+	key, ok := s.keys[clientID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrClientNotFound, clientID)
+	}
+
+	return &key, nil
+}
+
+// CanonicalString builds the signed message, one line per element:
+//
+//	METHOD
+//	/path
+//	a=1&a=2&b=3 (query sorted by key then value, URL encoded)
+//	hex(sha256(body))
+//	timestamp
+//	nonce
+func CanonicalString(method, path string, query url.Values, body []byte, timestamp, nonce string) string {
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(query))
+	for _, k := range keys {
+		values := append([]string(nil), query[k]...)
+		sort.Strings(values)
+		for _, v := range values {
+			pairs = append(pairs, url.QueryEscape(k)+"="+url.QueryEscape(v))
+		}
+	}
+
+	if path == "" {
+		path = "/"
+	}
+
+	return strings.Join([]string{
+		strings.ToUpper(method),
+		path,
+		strings.Join(pairs, "&"),
+		utils.Sha256HashHex(body),
+		timestamp,
+		nonce,
+	}, "\n")
+}
@@ -2,6 +2,7 @@ package utils
 
 const (
 	PathHealth  = "/health"
+	PathReady   = "/ready"
 	PathMetrics = "/metrics"
 )
 
@@ -0,0 +1,73 @@
+package integration
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"go-source/pkg/queue/event"
+	"go-source/pkg/queue/kafka/kafkatest"
+	"go-source/pkg/utils"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type entityCreated struct {
+	EntityID string `json:"entity_id"`
+	Status   string `json:"status"`
+}
+
+func TestKafka_PublishConsume(t *testing.T) {
+	const (
+		topic   = "entity-events"
+		groupID = "entity-service"
+	)
+
+	broker := kafkatest.NewBroker(kafkatest.WithPartitions(3))
+
+	registry := event.NewRegistry(nil)
+	require.NoError(t, event.Register[entityCreated](registry, "entity.created", 1))
+
+	var (
+		mu       sync.Mutex
+		received []entityCreated
+		traces   []string
+	)
+	router := event.NewRouter(registry)
+	require.NoError(t, event.Handle(router, "entity.created", 1,
+		func(ctx context.Context, env *event.Envelope, data *entityCreated) error {
+			mu.Lock()
+			defer mu.Unlock()
+			received = append(received, *data)
+			traces = append(traces, utils.GetRequestIdByContext(ctx).RequestID)
+			return nil
+		}))
+
+	consumer := broker.NewConsumer(groupID, []string{topic})
+	consumer.OnEvent(router.OnEvent)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		_ = consumer.Start(ctx)
+	}()
+
+	publisher := event.NewPublisher(broker.NewProducer(topic), registry, "test")
+	pubCtx := context.WithValue(context.Background(), utils.KeyTraceInfo, utils.TraceInfo{RequestID: "req-1"})
+	for _, id := range []string{"1", "2", "3", "4"} {
+		require.NoError(t, publisher.Publish(pubCtx, id, entityCreated{EntityID: id, Status: "ACTIVE"}))
+	}
+
+	msgs := broker.AssertPublished(t, topic, 4)
+	kafkatest.AssertHeader(t, msgs[0], utils.KeyTraceInfo, `{"request_id":"req-1"}`)
+
+	broker.WaitConsumed(t, groupID, topic, time.Second)
+	consumer.Shutdown(context.Background())
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Len(t, received, 4)
+	assert.Equal(t, []string{"req-1", "req-1", "req-1", "req-1"}, traces)
+}
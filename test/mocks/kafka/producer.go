@@ -0,0 +1,235 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: pkg/queue/kafka/producer.go
+//
+// Generated by this command:
+//
+//	mockgen -source=pkg/queue/kafka/producer.go -destination=test/mocks/kafka/producer.go -package=mocks
+//
+
+// Package mocks is a generated GoMock package.
+package mocks
+
+import (
+	context "context"
+	kafka0 "go-source/pkg/queue/kafka"
+	reflect "reflect"
+
+	kafka "github.com/confluentinc/confluent-kafka-go/kafka"
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockPublisherInterface is a mock of PublisherInterface interface.
+type MockPublisherInterface struct {
+	ctrl     *gomock.Controller
+	recorder *MockPublisherInterfaceMockRecorder
+	isgomock struct{}
+}
+
+// MockPublisherInterfaceMockRecorder is the mock recorder for MockPublisherInterface.
+type MockPublisherInterfaceMockRecorder struct {
+	mock *MockPublisherInterface
+}
+
+// NewMockPublisherInterface creates a new mock instance.
+func NewMockPublisherInterface(ctrl *gomock.Controller) *MockPublisherInterface {
+	mock := &MockPublisherInterface{ctrl: ctrl}
+	mock.recorder = &MockPublisherInterfaceMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockPublisherInterface) EXPECT() *MockPublisherInterfaceMockRecorder {
+	return m.recorder
+}
+
+// Publish mocks base method.
+func (m *MockPublisherInterface) Publish(ctx context.Context, key, value any) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Publish", ctx, key, value)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Publish indicates an expected call of Publish.
+func (mr *MockPublisherInterfaceMockRecorder) Publish(ctx, key, value any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Publish", reflect.TypeOf((*MockPublisherInterface)(nil).Publish), ctx, key, value)
+}
+
+// MockProducerInterface is a mock of ProducerInterface interface.
+type MockProducerInterface struct {
+	ctrl     *gomock.Controller
+	recorder *MockProducerInterfaceMockRecorder
+	isgomock struct{}
+}
+
+// MockProducerInterfaceMockRecorder is the mock recorder for MockProducerInterface.
+type MockProducerInterfaceMockRecorder struct {
+	mock *MockProducerInterface
+}
+
+// NewMockProducerInterface creates a new mock instance.
+func NewMockProducerInterface(ctrl *gomock.Controller) *MockProducerInterface {
+	mock := &MockProducerInterface{ctrl: ctrl}
+	mock.recorder = &MockProducerInterfaceMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockProducerInterface) EXPECT() *MockProducerInterfaceMockRecorder {
+	return m.recorder
+}
+
+// Close mocks base method.
+func (m *MockProducerInterface) Close(ctx context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Close", ctx)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Close indicates an expected call of Close.
+func (mr *MockProducerInterfaceMockRecorder) Close(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Close", reflect.TypeOf((*MockProducerInterface)(nil).Close), ctx)
+}
+
+// GetTopicName mocks base method.
+func (m *MockProducerInterface) GetTopicName() string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTopicName")
+	ret0, _ := ret[0].(string)
+	return ret0
+}
+
+// GetTopicName indicates an expected call of GetTopicName.
+func (mr *MockProducerInterfaceMockRecorder) GetTopicName() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTopicName", reflect.TypeOf((*MockProducerInterface)(nil).GetTopicName))
+}
+
+// Publish mocks base method.
+func (m *MockProducerInterface) Publish(ctx context.Context, key, value any) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Publish", ctx, key, value)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Publish indicates an expected call of Publish.
+func (mr *MockProducerInterfaceMockRecorder) Publish(ctx, key, value any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Publish", reflect.TypeOf((*MockProducerInterface)(nil).Publish), ctx, key, value)
+}
+
+// PublishAsync mocks base method.
+func (m *MockProducerInterface) PublishAsync(ctx context.Context, key, value any, cb kafka0.DeliveryCallback) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PublishAsync", ctx, key, value, cb)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// PublishAsync indicates an expected call of PublishAsync.
+func (mr *MockProducerInterfaceMockRecorder) PublishAsync(ctx, key, value, cb any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PublishAsync", reflect.TypeOf((*MockProducerInterface)(nil).PublishAsync), ctx, key, value, cb)
+}
+
+// PublishBytes mocks base method.
+func (m *MockProducerInterface) PublishBytes(ctx context.Context, key, value []byte) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PublishBytes", ctx, key, value)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// PublishBytes indicates an expected call of PublishBytes.
+func (mr *MockProducerInterfaceMockRecorder) PublishBytes(ctx, key, value any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PublishBytes", reflect.TypeOf((*MockProducerInterface)(nil).PublishBytes), ctx, key, value)
+}
+
+// PublishMessage mocks base method.
+func (m *MockProducerInterface) PublishMessage(ctx context.Context, msg *kafka.Message) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PublishMessage", ctx, msg)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// PublishMessage indicates an expected call of PublishMessage.
+func (mr *MockProducerInterfaceMockRecorder) PublishMessage(ctx, msg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PublishMessage", reflect.TypeOf((*MockProducerInterface)(nil).PublishMessage), ctx, msg)
+}
+
+// PublishMessageSync mocks base method.
+func (m *MockProducerInterface) PublishMessageSync(ctx context.Context, msg *kafka.Message) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PublishMessageSync", ctx, msg)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// PublishMessageSync indicates an expected call of PublishMessageSync.
+func (mr *MockProducerInterfaceMockRecorder) PublishMessageSync(ctx, msg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PublishMessageSync", reflect.TypeOf((*MockProducerInterface)(nil).PublishMessageSync), ctx, msg)
+}
+
+// PublishSync mocks base method.
+func (m *MockProducerInterface) PublishSync(ctx context.Context, key, value any) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PublishSync", ctx, key, value)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// PublishSync indicates an expected call of PublishSync.
+func (mr *MockProducerInterfaceMockRecorder) PublishSync(ctx, key, value any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PublishSync", reflect.TypeOf((*MockProducerInterface)(nil).PublishSync), ctx, key, value)
+}
+
+// PublishWithPartition mocks base method.
+func (m *MockProducerInterface) PublishWithPartition(ctx context.Context, key, value any, partition int32) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PublishWithPartition", ctx, key, value, partition)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// PublishWithPartition indicates an expected call of PublishWithPartition.
+func (mr *MockProducerInterfaceMockRecorder) PublishWithPartition(ctx, key, value, partition any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PublishWithPartition", reflect.TypeOf((*MockProducerInterface)(nil).PublishWithPartition), ctx, key, value, partition)
+}
+
+// PublishWithPartitionCRC32 mocks base method.
+func (m *MockProducerInterface) PublishWithPartitionCRC32(ctx context.Context, key, value any) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PublishWithPartitionCRC32", ctx, key, value)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// PublishWithPartitionCRC32 indicates an expected call of PublishWithPartitionCRC32.
+func (mr *MockProducerInterfaceMockRecorder) PublishWithPartitionCRC32(ctx, key, value any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PublishWithPartitionCRC32", reflect.TypeOf((*MockProducerInterface)(nil).PublishWithPartitionCRC32), ctx, key, value)
+}
+
+// PublishWithTopic mocks base method.
+func (m *MockProducerInterface) PublishWithTopic(ctx context.Context, topic string, key, value any) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PublishWithTopic", ctx, topic, key, value)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// PublishWithTopic indicates an expected call of PublishWithTopic.
+func (mr *MockProducerInterfaceMockRecorder) PublishWithTopic(ctx, topic, key, value any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PublishWithTopic", reflect.TypeOf((*MockProducerInterface)(nil).PublishWithTopic), ctx, topic, key, value)
+}